@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/alexedwards/scs/v2"
@@ -11,36 +12,95 @@ import (
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"thabomoyo.co.uk/internal/diff"
+	"thabomoyo.co.uk/internal/live"
 	"thabomoyo.co.uk/internal/models"
 	"time"
 )
 
 type Application struct {
 	Logger         *slog.Logger
-	Snippets       *models.SnippetModel
-	Users          *models.UserModel
+	Snippets       models.SnippetModelInterface
+	Users          models.UserModelInterface
+	Tokens         models.TokenModelInterface
+	Stars          models.StarModelInterface
+	Comments       models.CommentModelInterface
+	Live           *live.Hub
+	Notifier       *live.Publisher
 	TemplateCache  map[string]*template.Template
 	FormDecoder    *form.Decoder
 	SessionManager *scs.SessionManager
+	TicketSecret   []byte
 	Authenticated  bool
 	DebugMode      bool
 }
 
 type TemplateData struct {
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	CurrentYear     int
-	ErrorMessage    string
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            models.User
+	Snippet             models.Snippet
+	Snippets            []models.Snippet
+	CurrentYear         int
+	ErrorMessage        string
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	User                models.User
+	Query               string
+	Tag                 string
+	Starred             bool
+	StarredSnippets     []models.Snippet
+	Window              string
+	Comment             models.Comment
+	Threads             []models.Thread
+	Source              bool
+	Pagination          Pagination
+	Tokens              []models.Token
+	NewToken            string
+	Revisions           []models.Revision
+	Diff                DiffView
+}
+
+// DiffView is a comparison of two revisions of a snippet, file by file. Split
+// picks whether files are shown as unified hunks or side-by-side rows.
+type DiffView struct {
+	From  models.Revision
+	To    models.Revision
+	Files []FileDiff
+	Split bool
+}
+
+// FileDiff compares one file between two revisions. Added and Removed say
+// whether the file is only in the newer or only in the older revision.
+type FileDiff struct {
+	Name    string
+	Added   bool
+	Removed bool
+	Hunks   []diff.Hunk
+	Rows    []diff.Row
+}
+
+// Pagination holds the links to the pages either side of the one being
+// rendered. An empty URL means there is no page in that direction.
+type Pagination struct {
+	PrevURL string
+	NextURL string
 }
 
 type contextKey string
 
-const IsAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	IsAuthenticatedContextKey     = contextKey("isAuthenticated")
+	AuthenticatedUserIDContextKey = contextKey("authenticatedUserID")
+)
+
+// PublishEvent tells everyone watching a snippet that it has changed: the
+// viewers connected to this app's own hub, and the notification server's
+// subscribers if one is configured.
+func (app *Application) PublishEvent(snippetID int, eventType string) {
+	app.Live.Publish(snippetID, eventType)
+	app.Notifier.Publish(snippetID, eventType)
+}
 
 func (app *Application) ServerError(w http.ResponseWriter, r *http.Request, err error) {
 	var (
@@ -62,6 +122,65 @@ func (app *Application) ClientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// APIError is the body of every JSON error response, wrapped in an "error"
+// envelope.
+type APIError struct {
+	Status  int                 `json:"status"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+func (app *Application) WriteJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		app.APIServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+func (app *Application) ErrorJSON(w http.ResponseWriter, r *http.Request, status int, message string, fields map[string][]string) {
+	app.WriteJSON(w, r, status, map[string]APIError{
+		"error": {Status: status, Message: message, Fields: fields},
+	})
+}
+
+func (app *Application) ClientErrorJSON(w http.ResponseWriter, r *http.Request, status int) {
+	app.ErrorJSON(w, r, status, http.StatusText(status), nil)
+}
+
+func (app *Application) APIServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.Logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if app.DebugMode {
+		message = err.Error()
+	}
+
+	app.ErrorJSON(w, r, http.StatusInternalServerError, message, nil)
+}
+
+// DecodeJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and trailing data.
+func (app *Application) DecodeJSON(r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return err
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON object")
+	}
+
+	return nil
+}
+
 func (app *Application) Render(w http.ResponseWriter, r *http.Request, status int, page string, data TemplateData) {
 	ts, ok := app.TemplateCache[page]
 	if !ok {
@@ -86,11 +205,12 @@ func (app *Application) Render(w http.ResponseWriter, r *http.Request, status in
 
 func (app *Application) NewTemplateData(r *http.Request) TemplateData {
 	return TemplateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.SessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.IsAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
-		User:            models.User{},
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.SessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.IsAuthenticated(r),
+		AuthenticatedUserID: app.AuthenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
+		User:                models.User{},
 	}
 }
 
@@ -124,6 +244,17 @@ func (app *Application) IsAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
+// AuthenticatedUserID returns the ID of the user authenticated by either their
+// session or an API token, or 0 if the request is anonymous.
+func (app *Application) AuthenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(AuthenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
 func (app *Application) RecoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
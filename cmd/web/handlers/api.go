@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/live"
+	"thabomoyo.co.uk/internal/models"
+	"thabomoyo.co.uk/internal/ticket"
+	"time"
+)
+
+type APIHandler struct {
+	App *config.Application
+}
+
+type snippetListResponse struct {
+	Snippets []models.Snippet `json:"snippets"`
+	Next     string           `json:"next,omitempty"`
+	Prev     string           `json:"prev,omitempty"`
+}
+
+func (a *APIHandler) SnippetList(w http.ResponseWriter, r *http.Request) {
+	form, opts, err := decodeListForm(a.App, r, "/api/v1/snippets", models.ListOptions{})
+	if err != nil {
+		a.App.ErrorJSON(w, r, http.StatusBadRequest, "invalid query string", nil)
+		return
+	}
+
+	if !form.Valid() {
+		a.App.ErrorJSON(w, r, http.StatusBadRequest, "invalid query string", form.FieldErrors)
+		return
+	}
+
+	page, err := a.App.Snippets.List(opts)
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	res := snippetListResponse{Snippets: page.Snippets}
+	if res.Snippets == nil {
+		res.Snippets = []models.Snippet{}
+	}
+
+	// The API has no way to unlock a snippet, so other people's
+	// password-protected snippets are listed without their content. Nor are
+	// view-limited ones, as listing doesn't count as a view.
+	for i, snippet := range res.Snippets {
+		if (snippet.Protected || snippet.Limited()) && snippet.UserID != a.App.AuthenticatedUserID(r) {
+			res.Snippets[i].Content = ""
+		}
+	}
+	if page.Next != nil {
+		res.Next = page.Next.String()
+	}
+	if page.Prev != nil {
+		res.Prev = page.Prev.String()
+	}
+
+	a.App.WriteJSON(w, r, http.StatusOK, res)
+}
+
+func (a *APIHandler) SnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := a.snippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Fetching a view-limited snippet through the API counts as a view, and
+	// there's no confirmation step before the final one.
+	if snippet.Limited() && snippet.UserID != a.App.AuthenticatedUserID(r) {
+		var err error
+
+		snippet, err = a.App.Snippets.View(snippet.ID, true)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+			} else {
+				a.App.APIServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	a.App.WriteJSON(w, r, http.StatusOK, snippet)
+}
+
+func (a *APIHandler) SnippetCreate(w http.ResponseWriter, r *http.Request) {
+	form, ok := a.decodeSnippetForm(w, r, snippetCreateForm{
+		Format:     models.FormatCode,
+		Expires:    "1w",
+		Visibility: models.VisibilityPublic,
+	})
+	if !ok {
+		return
+	}
+
+	var err error
+
+	form.ForkedFrom, err = forkSource(a.App, r, form.ForkedFrom)
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	snippet, err := form.snippet()
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	snippet.UserID = a.App.AuthenticatedUserID(r)
+
+	err = a.App.Snippets.Insert(&snippet)
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	snippet, err = a.App.Snippets.Get(snippet.ID)
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", snippet.ID))
+	a.App.WriteJSON(w, r, http.StatusCreated, snippet)
+}
+
+func (a *APIHandler) SnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := a.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	expires, expiresAt := keepExpiry(snippet)
+
+	defaults := snippetCreateForm{
+		Title:      snippet.Title,
+		Files:      fileForms(snippet.Files),
+		Format:     snippet.Format,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: snippet.Visibility,
+		Expires:    expires,
+		ExpiresAt:  expiresAt,
+		MaxViews:   snippet.MaxViews,
+	}
+	defaults.edit(snippet)
+
+	form, ok := a.decodeSnippetForm(w, r, defaults)
+	if !ok {
+		return
+	}
+
+	updated, err := form.snippet()
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	updated.ID = snippet.ID
+
+	err = a.App.Snippets.Update(updated, a.App.AuthenticatedUserID(r))
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	a.App.PublishEvent(snippet.ID, live.EventUpdated)
+
+	snippet, err = a.App.Snippets.Get(snippet.ID)
+	if err != nil {
+		a.App.APIServerError(w, r, err)
+		return
+	}
+
+	a.App.WriteJSON(w, r, http.StatusOK, snippet)
+}
+
+func (a *APIHandler) SnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := a.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.App.Snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+		} else {
+			a.App.APIServerError(w, r, err)
+		}
+		return
+	}
+
+	a.App.PublishEvent(snippet.ID, live.EventDeleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// snippet loads the snippet named by the {id} path value, writing a JSON
+// error response and returning false if it can't.
+func (a *APIHandler) snippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := a.App.Snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+		} else {
+			a.App.APIServerError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	// As on the web, unlisted snippets can't be reached by their ID.
+	if !snippet.VisibleByIDTo(a.App.AuthenticatedUserID(r)) {
+		a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+		return models.Snippet{}, false
+	}
+
+	if snippet.Protected && snippet.UserID != a.App.AuthenticatedUserID(r) {
+		a.App.ErrorJSON(w, r, http.StatusForbidden, "snippet is password protected", nil)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// ownedSnippet is like snippet but also requires the current user to own it.
+func (a *APIHandler) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := a.snippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if snippet.UserID != a.App.AuthenticatedUserID(r) {
+		a.App.ClientErrorJSON(w, r, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// decodeSnippetForm decodes and validates a JSON snippet body on top of
+// defaults, so fields left out of the body keep their default values. It
+// writes a JSON error response and returns false if the body is malformed or
+// invalid.
+func (a *APIHandler) decodeSnippetForm(w http.ResponseWriter, r *http.Request, defaults snippetCreateForm) (snippetCreateForm, bool) {
+	form := defaults
+
+	// Requiring a JSON content type means a cross-site HTML form can't post
+	// here, since browsers won't send one without a CORS preflight.
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		a.App.ErrorJSON(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return form, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSnippetFormBytes)
+
+	err = a.App.DecodeJSON(r, &form)
+	if err != nil {
+		a.App.ErrorJSON(w, r, http.StatusBadRequest, "request body must be a valid snippet JSON object", nil)
+		return form, false
+	}
+
+	form.prepareFiles()
+	form.validate()
+
+	if !form.Valid() {
+		a.App.ErrorJSON(w, r, http.StatusUnprocessableEntity, "validation failed", form.FieldErrors)
+		return form, false
+	}
+
+	return form, true
+}
+
+// maxTicketChannels is the most channels a single ticket can grant.
+const maxTicketChannels = 20
+
+type ticketRequest struct {
+	Channels []string `json:"channels"`
+}
+
+type ticketResponse struct {
+	Ticket  string    `json:"ticket"`
+	Expires time.Time `json:"expires"`
+}
+
+// TicketCreate issues a ticket the current user can present to the WebSocket
+// server when connecting. The server only lets a connection subscribe to its
+// user's own channels and the ones its ticket grants, so the body can list
+// snippet channels like "snippet:42" to watch; each must be a snippet the
+// user could fetch from the API. It's a 404 unless a ticket secret is
+// configured.
+func (a *APIHandler) TicketCreate(w http.ResponseWriter, r *http.Request) {
+	if len(a.App.TicketSecret) == 0 {
+		a.App.ClientErrorJSON(w, r, http.StatusNotFound)
+		return
+	}
+
+	var req ticketRequest
+
+	if r.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			a.App.ErrorJSON(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSnippetFormBytes)
+
+		err = a.App.DecodeJSON(r, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			a.App.ErrorJSON(w, r, http.StatusBadRequest, "request body must be a valid ticket JSON object", nil)
+			return
+		}
+	}
+
+	userID := a.App.AuthenticatedUserID(r)
+
+	var problems []string
+
+	if len(req.Channels) > maxTicketChannels {
+		problems = append(problems, fmt.Sprintf("can't have more than %d channels", maxTicketChannels))
+	}
+
+	for _, channel := range req.Channels {
+		ok, err := a.canWatch(channel, userID)
+		if err != nil {
+			a.App.APIServerError(w, r, err)
+			return
+		}
+		if !ok {
+			problems = append(problems, fmt.Sprintf("can't watch %q", channel))
+		}
+	}
+
+	if len(problems) > 0 {
+		a.App.ErrorJSON(w, r, http.StatusUnprocessableEntity, "validation failed", map[string][]string{"channels": problems})
+		return
+	}
+
+	t, expires := ticket.Issue(a.App.TicketSecret, ticket.Claims{UserID: userID, Channels: req.Channels}, time.Now())
+
+	a.App.WriteJSON(w, r, http.StatusCreated, ticketResponse{Ticket: t, Expires: expires})
+}
+
+// canWatch reports whether the user may subscribe to a snippet's channel,
+// which they can if they could fetch the snippet itself.
+func (a *APIHandler) canWatch(channel string, userID int) (bool, error) {
+	rest, ok := strings.CutPrefix(channel, "snippet:")
+	if !ok {
+		return false, nil
+	}
+
+	id, err := strconv.Atoi(rest)
+	if err != nil || id < 1 || live.Channel(id) != channel {
+		return false, nil
+	}
+
+	snippet, err := a.App.Snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return snippet.VisibleByIDTo(userID) && (!snippet.Protected || snippet.UserID == userID), nil
+}
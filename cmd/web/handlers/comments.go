@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"thabomoyo.co.uk/internal/live"
+	"thabomoyo.co.uk/internal/models"
+	"thabomoyo.co.uk/internal/validator"
+)
+
+// commentForm is the form for posting a comment, optionally on a line of one
+// of the snippet's files. File defaults to the first file when only Line is
+// given.
+type commentForm struct {
+	Body                string `form:"body"`
+	File                int    `form:"file"`
+	Line                int    `form:"line"`
+	validator.Validator `form:"-"`
+}
+
+// maxCommentChars is the longest a comment can be.
+const maxCommentChars = 2000
+
+func (form *commentForm) validateBody() {
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, maxCommentChars), "body", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentChars))
+}
+
+// validate checks the body, and that the line the comment is on exists in
+// snippet.
+func (form *commentForm) validate(snippet models.Snippet) {
+	form.validateBody()
+
+	if form.Line == 0 {
+		form.File = 0
+		return
+	}
+	if form.File == 0 {
+		form.File = 1
+	}
+
+	file, ok := snippet.File(form.File)
+	if !ok {
+		form.AddFieldError("file", "This field must be one of the snippet's files")
+		return
+	}
+
+	form.CheckField(form.Line >= 1 && form.Line <= file.Lines(), "line", fmt.Sprintf("This field must be a line number from 1 to %d", file.Lines()))
+}
+
+// SnippetCommentPost posts a comment on the snippet.
+func (s *SnippetHandler) SnippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentForm
+
+	err := s.App.DecodePostForm(r, &form)
+	if err != nil {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(snippet)
+	if !form.Valid() {
+		s.renderSnippet(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	comment := models.Comment{
+		SnippetID: snippet.ID,
+		UserID:    s.App.AuthenticatedUserID(r),
+		File:      form.File,
+		Line:      form.Line,
+		Body:      form.Body,
+	}
+
+	err = s.App.Comments.Insert(&comment)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	s.App.PublishEvent(snippet.ID, live.EventCommented)
+
+	s.App.SessionManager.Put(r.Context(), "flash", "Comment posted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s#comment-%d", snippet.Slug, comment.ID), http.StatusSeeOther)
+}
+
+// ownedComment loads the comment named by the {id} path value if the current
+// user wrote it. If not, it writes an error response and returns false.
+func (s *SnippetHandler) ownedComment(w http.ResponseWriter, r *http.Request) (models.Comment, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return models.Comment{}, false
+	}
+
+	comment, err := s.App.Comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return models.Comment{}, false
+	}
+
+	if comment.UserID != s.App.AuthenticatedUserID(r) {
+		s.App.ClientError(w, http.StatusForbidden)
+		return models.Comment{}, false
+	}
+
+	return comment, true
+}
+
+func (s *SnippetHandler) CommentEdit(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Comment = comment
+	data.Form = commentForm{Body: comment.Body}
+
+	s.App.Render(w, r, http.StatusOK, "comment_edit.tmpl", data)
+}
+
+func (s *SnippetHandler) CommentEditPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentForm
+
+	err := s.App.DecodePostForm(r, &form)
+	if err != nil {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validateBody()
+	if !form.Valid() {
+		data := s.App.NewTemplateData(r)
+		data.Comment = comment
+		data.Form = form
+		s.App.Render(w, r, http.StatusUnprocessableEntity, "comment_edit.tmpl", data)
+		return
+	}
+
+	err = s.App.Comments.Update(comment.ID, form.Body)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	s.App.PublishEvent(comment.SnippetID, live.EventCommented)
+
+	s.App.SessionManager.Put(r.Context(), "flash", "Comment updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s#comment-%d", comment.SnippetSlug, comment.ID), http.StatusSeeOther)
+}
+
+func (s *SnippetHandler) CommentDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.App.Comments.Delete(comment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	s.App.PublishEvent(comment.SnippetID, live.EventCommented)
+
+	s.App.SessionManager.Put(r.Context(), "flash", "Comment deleted!")
+
+	http.Redirect(w, r, "/s/"+comment.SnippetSlug+"#comments", http.StatusSeeOther)
+}
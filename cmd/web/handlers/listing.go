@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/models"
+	"thabomoyo.co.uk/internal/validator"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type snippetListForm struct {
+	Sort                string `form:"sort"`
+	Size                int    `form:"size"`
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	Expiring            bool   `form:"expiring"`
+	Author              int    `form:"author"`
+	After               string `form:"after"`
+	Before              string `form:"before"`
+	Action              string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// values returns the form's filters as query parameters, leaving out the
+// cursors and anything still at its default.
+func (form *snippetListForm) values() url.Values {
+	v := url.Values{}
+
+	if form.Sort != models.SortNewest {
+		v.Set("sort", form.Sort)
+	}
+	if form.Size != 10 {
+		v.Set("size", strconv.Itoa(form.Size))
+	}
+	if form.From != "" {
+		v.Set("from", form.From)
+	}
+	if form.To != "" {
+		v.Set("to", form.To)
+	}
+	if form.Expiring {
+		v.Set("expiring", "true")
+	}
+	if form.Author != 0 {
+		v.Set("author", strconv.Itoa(form.Author))
+	}
+
+	return v
+}
+
+// pageURL returns the link to the page on the given side of cursor.
+func (form *snippetListForm) pageURL(key string, cursor *models.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	v := form.values()
+	v.Set(key, cursor.String())
+
+	return form.Action + "?" + v.Encode()
+}
+
+// decodeListForm decodes and validates the listing filters in the query
+// string, returning them along with the matching ListOptions on top of the
+// fixed filters in base. Validation failures are recorded on the form.
+func decodeListForm(app *config.Application, r *http.Request, action string, base models.ListOptions) (snippetListForm, models.ListOptions, error) {
+	form := snippetListForm{Action: action}
+
+	err := app.FormDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		return form, base, err
+	}
+
+	if form.Sort == "" {
+		form.Sort = models.SortNewest
+	}
+	if form.Size == 0 {
+		form.Size = 10
+	}
+
+	opts := base
+	opts.Sort = form.Sort
+	opts.Size = form.Size
+
+	// A fixed author in base, like on a user's own listing, can't be overridden.
+	if form.Author != 0 && base.UserID == 0 {
+		opts.UserID = form.Author
+	}
+	if form.Expiring {
+		opts.ExpiresWithin = 24 * time.Hour
+	}
+
+	form.CheckField(validator.PermittedValue(form.Sort, models.SortNewest, models.SortOldest, models.SortExpiring), "sort", "This field must equal newest, oldest or expiring")
+	form.CheckField(validator.PermittedValue(form.Size, 10, 25, 50), "size", "This field must equal 10, 25 or 50")
+
+	if form.From != "" {
+		from, err := time.Parse(dateLayout, form.From)
+		form.CheckField(err == nil, "from", "This field must be a valid date")
+		opts.CreatedAfter = from
+	}
+	if form.To != "" {
+		to, err := time.Parse(dateLayout, form.To)
+		form.CheckField(err == nil, "to", "This field must be a valid date")
+		// Include the whole of the final day.
+		opts.CreatedBefore = to.AddDate(0, 0, 1)
+	}
+
+	if form.After != "" {
+		cursor, err := models.ParseCursor(form.After)
+		form.CheckField(err == nil, "after", "This field must be a valid cursor")
+		opts.After = &cursor
+	} else if form.Before != "" {
+		cursor, err := models.ParseCursor(form.Before)
+		form.CheckField(err == nil, "before", "This field must be a valid cursor")
+		opts.Before = &cursor
+	}
+
+	return form, opts, nil
+}
+
+// listSnippets fetches the page of snippets requested by the query string on
+// top of the fixed filters in base, and fills in the snippets, form and
+// pagination links of data. It returns false if the query string was invalid
+// or the lookup failed, in which case a response has already been written.
+func listSnippets(app *config.Application, w http.ResponseWriter, r *http.Request, action string, base models.ListOptions, data *config.TemplateData) bool {
+	form, opts, err := decodeListForm(app, r, action, base)
+	if err != nil || !form.Valid() {
+		app.ClientError(w, http.StatusBadRequest)
+		return false
+	}
+
+	page, err := app.Snippets.List(opts)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return false
+	}
+
+	data.Snippets = page.Snippets
+	data.Form = form
+	data.Pagination = config.Pagination{
+		PrevURL: form.pageURL("before", page.Prev),
+		NextURL: form.pageURL("after", page.Next),
+	}
+
+	return true
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/diff"
+	"thabomoyo.co.uk/internal/live"
+	"thabomoyo.co.uk/internal/models"
+)
+
+type snippetRestoreForm struct {
+	Revision int `form:"revision"`
+}
+
+func (s *SnippetHandler) SnippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.App.Snippets.Revisions(snippet.ID)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	s.App.Render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+func (s *SnippetHandler) SnippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.App.Snippets.Revisions(snippet.ID)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	if len(revisions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// By default compare the latest revision with the one before it.
+	to := revisions[0].Number
+	from := max(1, to-1)
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err = strconv.Atoi(v)
+		if err != nil {
+			s.App.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = strconv.Atoi(v)
+		if err != nil {
+			s.App.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var view config.DiffView
+
+	view.From, err = s.App.Snippets.Revision(snippet.ID, from)
+	if err == nil {
+		view.To, err = s.App.Snippets.Revision(snippet.ID, to)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	view.Files = diffFiles(view.From.Files, view.To.Files)
+	view.Split = r.URL.Query().Get("view") == "split"
+
+	data := s.App.NewTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = view
+
+	s.App.Render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
+// diffFiles compares the files of two revisions. Named files are matched up
+// by name, so a file that's been renamed shows as one removed and one added.
+// Unnamed files are matched up in order. The result follows the newer
+// revision's order, with removed files last.
+func diffFiles(from, to []models.File) []config.FileDiff {
+	keys := func(files []models.File) []string {
+		keys := make([]string, len(files))
+		unnamed := 0
+
+		for i, f := range files {
+			if f.Name != "" {
+				keys[i] = "name:" + f.Name
+			} else {
+				keys[i] = "unnamed:" + strconv.Itoa(unnamed)
+				unnamed++
+			}
+		}
+
+		return keys
+	}
+
+	fromKeys, toKeys := keys(from), keys(to)
+
+	old := make(map[string]models.File, len(from))
+	for i, f := range from {
+		old[fromKeys[i]] = f
+	}
+
+	var files []config.FileDiff
+
+	for i, f := range to {
+		prev, ok := old[toKeys[i]]
+		delete(old, toKeys[i])
+
+		lines := diff.Lines(prev.Content, f.Content)
+		files = append(files, config.FileDiff{
+			Name:  f.DisplayName(),
+			Added: !ok,
+			Hunks: diff.Hunks(lines, 3),
+			Rows:  diff.SideBySide(lines),
+		})
+	}
+
+	for i, f := range from {
+		if _, ok := old[fromKeys[i]]; !ok {
+			continue
+		}
+
+		lines := diff.Lines(f.Content, "")
+		files = append(files, config.FileDiff{
+			Name:    f.DisplayName(),
+			Removed: true,
+			Hunks:   diff.Hunks(lines, 3),
+			Rows:    diff.SideBySide(lines),
+		})
+	}
+
+	return files
+}
+
+func (s *SnippetHandler) SnippetRestorePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetRestoreForm
+
+	err := s.App.DecodePostForm(r, &form)
+	if err != nil || form.Revision < 1 {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = s.App.Snippets.Restore(snippet.ID, form.Revision, s.App.AuthenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	s.App.PublishEvent(snippet.ID, live.EventUpdated)
+
+	s.App.SessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet restored to revision %d!", form.Revision))
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
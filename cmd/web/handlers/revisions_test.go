@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"thabomoyo.co.uk/internal/models"
+)
+
+func TestDiffFiles(t *testing.T) {
+	t.Parallel()
+
+	from := []models.File{
+		{Position: 0, Name: "main.go", Content: "package main\n"},
+		{Position: 1, Name: "util.go", Content: "package main\n\nfunc a() {}\n"},
+		{Position: 2, Content: "notes\n"},
+	}
+	to := []models.File{
+		{Position: 0, Name: "main.go", Content: "package main\n"},
+		{Position: 1, Content: "notes\nmore notes\n"},
+		{Position: 2, Name: "util_test.go", Content: "package main\n"},
+	}
+
+	files := diffFiles(from, to)
+
+	tests := []struct {
+		name    string
+		added   bool
+		removed bool
+		changed bool
+	}{
+		{name: "main.go"},
+		{name: "File 2", changed: true},
+		{name: "util_test.go", added: true, changed: true},
+		{name: "util.go", removed: true, changed: true},
+	}
+
+	assert.Equal(t, len(files), len(tests))
+
+	for i, tt := range tests {
+		if i >= len(files) {
+			break
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, files[i].Name, tt.name)
+			assert.Equal(t, files[i].Added, tt.added)
+			assert.Equal(t, files[i].Removed, tt.removed)
+			assert.Equal(t, len(files[i].Hunks) > 0, tt.changed)
+		})
+	}
+}
@@ -1,73 +1,932 @@
 package handlers
 
 import (
+	"archive/zip"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/highlight"
+	"thabomoyo.co.uk/internal/live"
 	"thabomoyo.co.uk/internal/models"
 	"thabomoyo.co.uk/internal/validator"
+	"time"
 )
 
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string            `form:"title" json:"title"`
+	Files               []snippetFileForm `form:"files" json:"files"`
+	Format              string            `form:"format" json:"format"`
+	Tags                string            `form:"tags" json:"tags"`
+	Visibility          string            `form:"visibility" json:"visibility"`
+	Expires             string            `form:"expires" json:"expires"`
+	ExpiresAt           string            `form:"expires_at" json:"expires_at"`
+	MaxViews            int               `form:"max_views" json:"max_views"`
+	Password            string            `form:"password" json:"password"`
+	RemovePassword      bool              `form:"remove_password" json:"remove_password"`
+	ForkedFrom          int               `form:"forked_from" json:"forked_from"`
+	validator.Validator `form:"-" json:"-"`
+
+	// Content and Language are shorthand for the main file's content and
+	// language, so API clients that only deal in one file needn't send Files.
+	Content  string `form:"-" json:"content"`
+	Language string `form:"-" json:"language"`
+
+	// AddFile asks for another empty file to be added to the form, rather
+	// than for the snippet to be saved.
+	AddFile bool `form:"add_file" json:"-"`
+
+	// expires is the expiry chosen on the form, worked out by validate.
+	expires time.Time
+
+	// editing is set when the form edits an existing snippet, whose exact
+	// expiry is currentExpires and which has been viewed currentViews times.
+	editing        bool
+	currentExpires time.Time
+	currentViews   int
+}
+
+// snippetFileForm is one file on the snippet form. Files with Remove ticked
+// are dropped before the form is validated.
+type snippetFileForm struct {
+	Name     string `form:"name" json:"name"`
+	Language string `form:"language" json:"language"`
+	Content  string `form:"content" json:"content"`
+	Remove   bool   `form:"remove" json:"-"`
+}
+
+// maxSnippetFiles is the most files a snippet can hold.
+const maxSnippetFiles = 10
+
+// maxSnippetFormBytes bounds the size of a snippet form body, allowing for
+// maxSnippetFiles files of multi-byte characters after URL encoding.
+const maxSnippetFormBytes = 128 * 1024
+
+// Bounds on the tags of a snippet.
+const (
+	maxTags      = 5
+	maxTagLength = 30
+)
+
+// fileForms returns form fields holding a snippet's files, for prefilling an
+// edit or a fork.
+func fileForms(files []models.File) []snippetFileForm {
+	forms := make([]snippetFileForm, len(files))
+	for i, f := range files {
+		forms[i] = snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	return forms
+}
+
+// prepareFiles applies the Content and Language shorthand, drops the files
+// marked for removal and, if asked, adds an empty one. It reports whether a
+// file was asked for, in which case the form should be shown again rather
+// than saved.
+func (form *snippetCreateForm) prepareFiles() bool {
+	if form.Content != "" || form.Language != "" {
+		if len(form.Files) == 0 {
+			form.Files = []snippetFileForm{{}}
+		}
+		if form.Content != "" {
+			form.Files[0].Content = form.Content
+		}
+		if form.Language != "" {
+			form.Files[0].Language = form.Language
+		}
+	}
+
+	var kept []snippetFileForm
+	for _, f := range form.Files {
+		if !f.Remove {
+			kept = append(kept, f)
+		}
+	}
+	form.Files = kept
+
+	if !form.AddFile {
+		return false
+	}
+
+	if len(form.Files) < maxSnippetFiles {
+		form.Files = append(form.Files, snippetFileForm{})
+	}
+
+	return true
+}
+
+// Expiry choices on the snippet form besides the presets below. With
+// expiresCustom the time is taken from ExpiresAt instead, and expiresKeep
+// leaves an edited snippet's expiry as it is.
+const (
+	expiresNever  = "never"
+	expiresCustom = "custom"
+	expiresKeep   = "keep"
+)
+
+// expiryPresets gives the expiry of each preset choice on the snippet form,
+// relative to now.
+var expiryPresets = map[string]func(now time.Time) time.Time{
+	"10m": func(now time.Time) time.Time { return now.Add(10 * time.Minute) },
+	"1h":  func(now time.Time) time.Time { return now.Add(time.Hour) },
+	"1d":  func(now time.Time) time.Time { return now.AddDate(0, 0, 1) },
+	"1w":  func(now time.Time) time.Time { return now.AddDate(0, 0, 7) },
+	"1mo": func(now time.Time) time.Time { return now.AddDate(0, 1, 0) },
+	"1y":  func(now time.Time) time.Time { return now.AddDate(1, 0, 0) },
+}
+
+// expiresAtLayout is the format of a datetime-local input. Times entered on
+// the form are taken to be UTC, as that's how they're displayed.
+const expiresAtLayout = "2006-01-02T15:04"
+
+// parseExpiresAt parses a custom expiry, accepting RFC 3339 from API clients
+// as well as the form's own layout.
+func parseExpiresAt(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t.UTC(), nil
+	}
+
+	return time.Parse(expiresAtLayout, value)
+}
+
+// keepExpiry returns the Expires and ExpiresAt form values that leave a
+// snippet's expiry as it is, for prefilling an edit. ExpiresAt is only a
+// starting point in case a custom expiry is chosen instead.
+func keepExpiry(snippet models.Snippet) (string, string) {
+	if snippet.Never() {
+		return expiresKeep, ""
+	}
+
+	return expiresKeep, snippet.Expires.UTC().Format(expiresAtLayout)
+}
+
+// edit marks the form as editing snippet, so validate can keep its expiry
+// and check a view limit against the views it's had.
+func (form *snippetCreateForm) edit(snippet models.Snippet) {
+	form.editing = true
+	form.currentExpires = snippet.Expires
+	form.currentViews = snippet.Views
+}
+
+func (form *snippetCreateForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Files) > 0, "files", "A snippet must have at least one file")
+	form.CheckField(len(form.Files) <= maxSnippetFiles, "files", fmt.Sprintf("A snippet can't have more than %d files", maxSnippetFiles))
+
+	names := map[string]bool{}
+
+	for i, f := range form.Files {
+		key := func(field string) string {
+			return fmt.Sprintf("files[%d].%s", i, field)
+		}
+
+		form.CheckField(validator.MaxChars(f.Name, 100), key("name"), "This field cannot be more than 100 characters long")
+		form.CheckField(!strings.ContainsAny(f.Name, `/\`), key("name"), "This field cannot contain slashes")
+		form.CheckField(f.Name == "" || !names[f.Name], key("name"), "Another file already has this name")
+		names[f.Name] = true
+
+		form.CheckField(validator.NotBlank(f.Content), key("content"), "This field cannot be blank")
+		form.CheckField(validator.MinChars(f.Content, 10), key("content"), "This field must be at least 10 characters long")
+		form.CheckField(validator.MinWordCount(f.Content, 5), key("content"), "This field must contain at least 5 words")
+		form.CheckField(validator.MaxChars(f.Content, 1000), key("content"), "This field must be less than 1000 characters long")
+		form.CheckField(f.Language == "" || validator.PermittedValue(f.Language, highlight.Keys()...), key("language"), "This field must be a supported language")
+	}
+
+	form.CheckField(validator.PermittedValue(form.Format, models.FormatPlain, models.FormatCode, models.FormatMarkdown), "format", "This field must equal plain, code or markdown")
+
+	tags := models.ParseTags(form.Tags)
+	form.CheckField(len(tags) <= maxTags, "tags", fmt.Sprintf("A snippet can't have more than %d tags", maxTags))
+	for _, tag := range tags {
+		form.CheckField(validator.MaxChars(tag, maxTagLength), "tags", fmt.Sprintf("Tag %q cannot be more than %d characters long", tag, maxTagLength))
+		form.CheckField(validator.Matches(tag, validator.TagRX), "tags", fmt.Sprintf("Tag %q can only contain letters, digits and single hyphens between them", tag))
+	}
+
+	form.CheckField(validator.PermittedValue(form.MaxViews, 0, 1, 10, 100), "max_views", "This field must equal 0, 1, 10 or 100")
+
+	// A limit the snippet has already used up would leave it neither
+	// viewable nor deleted.
+	if form.editing && form.MaxViews != 0 {
+		form.CheckField(form.MaxViews > form.currentViews, "max_views", fmt.Sprintf("This snippet has already been viewed %d times, so this must be more than that", form.currentViews))
+	}
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must equal public, unlisted or private")
+
+	// The password is optional, but bcrypt ignores anything past 72 bytes.
+	if form.Password != "" {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		form.CheckField(len(form.Password) <= 72, "password", "This field must be no more than 72 bytes long")
+		form.CheckField(!form.RemovePassword, "password", "This field must be blank when removing the password")
+	}
+
+	now := time.Now().UTC()
+
+	switch {
+	case form.Expires == expiresKeep && form.editing:
+		form.expires = form.currentExpires
+	case form.Expires == expiresNever:
+		form.expires = time.Time{}
+	case form.Expires == expiresCustom:
+		expires, err := parseExpiresAt(form.ExpiresAt)
+		if err != nil {
+			form.AddFieldError("expires_at", "This field must be a valid date and time")
+			break
+		}
+
+		// An API client sending back the expiry it was given isn't choosing
+		// a new one, so it needn't be in range.
+		if form.editing && expires.Equal(form.currentExpires) {
+			form.expires = form.currentExpires
+			break
+		}
+
+		form.CheckField(validator.TimeBetween(expires, now.Add(10*time.Minute), now.AddDate(1, 0, 0)), "expires_at", "This field must be between 10 minutes and one year from now")
+		form.expires = expires
+	case expiryPresets[form.Expires] != nil:
+		form.expires = expiryPresets[form.Expires](now)
+	default:
+		form.AddFieldError("expires", "This field must be one of the listed options")
+	}
+}
+
+// snippet returns the snippet described by the form, working out the language
+// of each file that hasn't got one from its name or content, and hashing the
+// password if one was given. Plain text snippets aren't highlighted whatever
+// language was chosen, and Markdown snippets default to Markdown.
+func (form *snippetCreateForm) snippet() (models.Snippet, error) {
+	files := make([]models.File, len(form.Files))
+
+	for i, f := range form.Files {
+		language := f.Language
+		switch {
+		case form.Format == models.FormatPlain:
+			language = highlight.Plaintext
+		case form.Format == models.FormatMarkdown && language == "":
+			language = "markdown"
+		}
+		if language == "" {
+			language = highlight.ForFilename(f.Name)
+		}
+		if language == "" {
+			language = highlight.Detect(f.Content)
+		}
+
+		files[i] = models.File{Name: f.Name, Language: language, Content: f.Content}
+	}
+
+	snippet := models.Snippet{
+		Title:          form.Title,
+		Files:          files,
+		Format:         form.Format,
+		Tags:           models.ParseTags(form.Tags),
+		Visibility:     form.Visibility,
+		MaxViews:       form.MaxViews,
+		Expires:        form.expires,
+		ForkedFrom:     form.ForkedFrom,
+		RemovePassword: form.RemovePassword,
+	}
+
+	if form.Password != "" {
+		err := snippet.SetPassword(form.Password)
+		if err != nil {
+			return models.Snippet{}, err
+		}
+	}
+
+	return snippet, nil
+}
+
+// forkSource returns id if it's a snippet the current user can reach by ID,
+// or 0 if not, so a fork can't claim to come from a snippet its author never
+// saw. Someone else's unlisted snippet doesn't count: showing "Forked from"
+// for it would confirm that a guessed ID exists.
+func forkSource(app *config.Application, r *http.Request, id int) (int, error) {
+	if id == 0 {
+		return 0, nil
+	}
+
+	source, err := app.Snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if !source.VisibleByIDTo(app.AuthenticatedUserID(r)) {
+		return 0, nil
+	}
+
+	return id, nil
+}
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
 
+// unlockDuration is how long a password-protected snippet stays unlocked
+// once the right password has been entered. The session may expire first.
+const unlockDuration = 10 * time.Minute
+
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlockedSnippet:%d", id)
+}
+
 type SnippetHandler struct {
 	App *config.Application
 }
 
+const searchPageSize = 10
+
+// maxSearchPage is the furthest page of results that can be asked for. Each
+// page further in makes MySQL rank and skip every row before it.
+const maxSearchPage = 1000
+
 func (s *SnippetHandler) Home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := s.App.Snippets.Latest()
+	data := s.App.NewTemplateData(r)
 
-	if err != nil {
-		s.App.ServerError(w, r, err)
+	if !listSnippets(s.App, w, r, "/", models.ListOptions{}, &data) {
 		return
 	}
-	data := s.App.NewTemplateData(r)
-	data.Snippets = snippets
 
 	s.App.Render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
-func (s *SnippetHandler) SnippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
+// SnippetTag lists the public snippets with the tag in the {name} path value.
+func (s *SnippetHandler) SnippetTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.PathValue("name"))
+	if !validator.Matches(tag, validator.TagRX) {
 		http.NotFound(w, r)
 		return
 	}
 
-	snippet, err := s.App.Snippets.Get(id)
+	data := s.App.NewTemplateData(r)
+	data.Tag = tag
+
+	if !listSnippets(s.App, w, r, "/tag/"+tag, models.ListOptions{Tag: tag}, &data) {
+		return
+	}
+
+	s.App.Render(w, r, http.StatusOK, "tag.tmpl", data)
+}
+
+// viewableSnippet loads the snippet named by the {slug} or {id} path value if
+// the current user may view it. If not, it writes an error response, or the
+// unlock form for a locked password-protected snippet, and returns false.
+// Every handler that shows a snippet's content goes through here.
+func (s *SnippetHandler) viewableSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := s.findSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if !s.unlocked(r, snippet) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		data := s.App.NewTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		s.App.Render(w, r, http.StatusForbidden, "unlock.tmpl", data)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// findSnippet is like viewableSnippet, but doesn't check the snippet's
+// password.
+func (s *SnippetHandler) findSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	var snippet models.Snippet
+	var err error
+
+	userID := s.App.AuthenticatedUserID(r)
+
+	if slug := r.PathValue("slug"); slug != "" {
+		snippet, err = s.App.Snippets.GetBySlug(slug)
+	} else {
+		id, convErr := strconv.Atoi(r.PathValue("id"))
+		if convErr != nil || id < 1 {
+			http.NotFound(w, r)
+			return models.Snippet{}, false
+		}
+
+		snippet, err = s.App.Snippets.Get(id)
+
+		// Sequential IDs are easy to guess, so only public snippets and the
+		// user's own can be reached by ID. Everything else needs its slug.
+		if err == nil && !snippet.VisibleByIDTo(userID) {
+			err = models.ErrNoRecord
+		}
+	}
+
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
 		} else {
 			s.App.ServerError(w, r, err)
 		}
+		return models.Snippet{}, false
+	}
+
+	// Respond as if private snippets don't exist, so their IDs don't leak.
+	if !snippet.VisibleTo(userID) {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	if snippet.Visibility == models.VisibilityPrivate || snippet.Protected {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	return snippet, true
+}
+
+// limitedFor reports whether viewing snippet counts towards its view limit
+// for the current user. Owners can always see their own snippets.
+func (s *SnippetHandler) limitedFor(r *http.Request, snippet models.Snippet) bool {
+	return snippet.Limited() && snippet.UserID != s.App.AuthenticatedUserID(r)
+}
+
+// unlimitedSnippet is like viewableSnippet, but responds with a 403 for a
+// view-limited snippet unless it's the current user's. It's used for the
+// pages that would otherwise show the content without counting a view.
+func (s *SnippetHandler) unlimitedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := s.viewableSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if s.limitedFor(r, snippet) {
+		s.App.ClientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// unlocked reports whether the current user may see the content of snippet:
+// it has no password, they own it, or they've recently entered its password.
+func (s *SnippetHandler) unlocked(r *http.Request, snippet models.Snippet) bool {
+	if !snippet.Protected || snippet.UserID == s.App.AuthenticatedUserID(r) {
+		return true
+	}
+
+	until := s.App.SessionManager.GetInt64(r.Context(), unlockKey(snippet.ID))
+
+	return time.Now().Unix() < until
+}
+
+func (s *SnippetHandler) SnippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+
+	var form snippetUnlockForm
+
+	err := s.App.DecodePostForm(r, &form)
+	if err != nil {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = snippet.CheckPassword(form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("Incorrect password")
+
+			data := s.App.NewTemplateData(r)
+			data.Snippet = snippet
+			data.Form = form
+			s.App.Render(w, r, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	s.App.SessionManager.Put(r.Context(), unlockKey(snippet.ID), time.Now().Add(unlockDuration).Unix())
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+func (s *SnippetHandler) SnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.viewableSnippet(w, r)
+	if !ok {
 		return
 	}
 
+	if s.limitedFor(r, snippet) {
+		w.Header().Set("Cache-Control", "no-store")
+		s.viewSnippet(w, r, snippet, false)
+		return
+	}
+
+	// The comment form can be pointed at a line, as the reply links are.
+	file, _ := strconv.Atoi(r.URL.Query().Get("file"))
+	line, _ := strconv.Atoi(r.URL.Query().Get("line"))
+
+	s.renderSnippet(w, r, http.StatusOK, snippet, commentForm{File: file, Line: line})
+}
+
+// renderSnippet renders the view page for a snippet whose views aren't
+// limited, along with its comments and the given comment form. A Markdown
+// snippet is shown as its source if the source query parameter is set.
+func (s *SnippetHandler) renderSnippet(w http.ResponseWriter, r *http.Request, status int, snippet models.Snippet, form commentForm) {
 	data := s.App.NewTemplateData(r)
 	data.Snippet = snippet
+	data.Form = form
+	data.Source = r.URL.Query().Get("source") != ""
+
+	if userID := s.App.AuthenticatedUserID(r); userID != 0 {
+		starred, err := s.App.Stars.Exists(snippet.ID, userID)
+		if err != nil {
+			s.App.ServerError(w, r, err)
+			return
+		}
+		data.Starred = starred
+	}
+
+	comments, err := s.App.Comments.BySnippet(snippet.ID)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+	data.Threads = models.Threads(comments)
+
+	s.App.Render(w, r, status, "view.tmpl", data)
+}
+
+// SnippetLive upgrades the request to a WebSocket that's told whenever the
+// snippet is edited or commented on, so the page can offer a reload. It's
+// open to the same viewers as the page itself.
+func (s *SnippetHandler) SnippetLive(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.unlocked(r, snippet) || s.limitedFor(r, snippet) {
+		s.App.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := s.App.Live.Upgrade(w, r, snippet.ID)
+	if err != nil {
+		s.App.Logger.Warn("live upgrade failed", "err", err.Error(), "uri", r.URL.RequestURI())
+	}
+}
+
+// SnippetStarPost stars the snippet, or unstars it if the user had already
+// starred it.
+func (s *SnippetHandler) SnippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	starred, err := s.App.Stars.Toggle(snippet.ID, s.App.AuthenticatedUserID(r))
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	if starred {
+		s.App.SessionManager.Put(r.Context(), "flash", "Snippet starred!")
+	} else {
+		s.App.SessionManager.Put(r.Context(), "flash", "Star removed")
+	}
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// popularPageSize is the number of snippets ranked on the popular page.
+const popularPageSize = 25
+
+// popularWindows gives the start of each time window the popular page can
+// rank stars over, relative to now. The zero time means all time.
+var popularWindows = map[string]func(now time.Time) time.Time{
+	"week":  func(now time.Time) time.Time { return now.AddDate(0, 0, -7) },
+	"month": func(now time.Time) time.Time { return now.AddDate(0, -1, 0) },
+	"year":  func(now time.Time) time.Time { return now.AddDate(-1, 0, 0) },
+	"all":   func(now time.Time) time.Time { return time.Time{} },
+}
+
+// SnippetPopular ranks public snippets by the stars they've been given in
+// the time window chosen by the window query parameter, a week by default.
+func (s *SnippetHandler) SnippetPopular(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "week"
+	}
+
+	since, ok := popularWindows[window]
+	if !ok {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, err := s.App.Stars.Popular(since(time.Now().UTC()), popularPageSize)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Snippets = snippets
+	data.Window = window
+
+	s.App.Render(w, r, http.StatusOK, "popular.tmpl", data)
+}
+
+// SnippetRevealPost shows a view-limited snippet after the reader has
+// confirmed they want to spend its final view.
+func (s *SnippetHandler) SnippetRevealPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.limitedFor(r, snippet) {
+		http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	s.viewSnippet(w, r, snippet, true)
+}
+
+// viewSnippet counts a view of a view-limited snippet and renders it. If
+// someone else used up the last view first, it responds with a 404.
+//
+// Unless confirmed is set, the reader is asked before the final view is
+// spent, so the snippet isn't burnt by a link preview or someone opening it
+// by mistake. Whether it's the final view is decided with the row locked, so
+// a view counted by someone else in the meantime can't slip past the
+// question.
+func (s *SnippetHandler) viewSnippet(w http.ResponseWriter, r *http.Request, snippet models.Snippet, confirmed bool) {
+	viewed, err := s.App.Snippets.View(snippet.ID, confirmed)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNeedsConfirm):
+			data := s.App.NewTemplateData(r)
+			data.Snippet = snippet
+			s.App.Render(w, r, http.StatusOK, "reveal.tmpl", data)
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		default:
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Snippet = viewed
 
 	s.App.Render(w, r, http.StatusOK, "view.tmpl", data)
 }
 
+func (s *SnippetHandler) SnippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	file, ok := requestedFile(w, r, snippet)
+	if !ok {
+		return
+	}
+
+	serveFileContent(w, r, snippet, file)
+}
+
+// SnippetDownload downloads a single-file snippet as that file, and a
+// snippet with several files as a zip archive of them.
+func (s *SnippetHandler) SnippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if len(snippet.Files) > 1 {
+		s.serveZip(w, r, snippet)
+		return
+	}
+
+	file, ok := requestedFile(w, r, snippet)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fileFilename(snippet, file),
+	}))
+
+	serveFileContent(w, r, snippet, file)
+}
+
+// requestedFile returns the file of snippet numbered by the {file} path
+// value, or its main file if there's no such path value. It responds with a
+// 404 and returns false if there's no such file.
+func requestedFile(w http.ResponseWriter, r *http.Request, snippet models.Snippet) (models.File, bool) {
+	number := 1
+
+	if v := r.PathValue("file"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.NotFound(w, r)
+			return models.File{}, false
+		}
+		number = n
+	}
+
+	file, ok := snippet.File(number)
+	if !ok {
+		http.NotFound(w, r)
+		return models.File{}, false
+	}
+
+	return file, true
+}
+
+// serveFileContent writes a snippet file's content as plain text, with an
+// ETag and Last-Modified so clients can revalidate rather than re-download it.
+func serveFileContent(w http.ResponseWriter, r *http.Request, snippet models.Snippet, file models.File) {
+	hash := sha256.Sum256([]byte(snippet.Title + "\x00" + file.Name + "\x00" + file.Language + "\x00" + file.Content))
+
+	// Allow private caching, overriding the no-store set for authenticated
+	// pages, but make clients check back before reusing it.
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, hash[:16]))
+
+	http.ServeContent(w, r, "", snippet.Updated, strings.NewReader(file.Content))
+}
+
+// serveZip writes every file of a snippet as a zip archive.
+func (s *SnippetHandler) serveZip(w http.ResponseWriter, r *http.Request, snippet models.Snippet) {
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": downloadBaseName(snippet) + ".zip",
+	}))
+
+	zw := zip.NewWriter(w)
+
+	for _, file := range snippet.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     fileFilename(snippet, file),
+			Method:   zip.Deflate,
+			Modified: snippet.Updated,
+		})
+		if err == nil {
+			_, err = io.WriteString(fw, file.Content)
+		}
+		if err != nil {
+			// The headers have gone, so all we can do is log it.
+			s.App.Logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+			return
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		s.App.Logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	}
+}
+
+// downloadBaseName builds a filename, without an extension, for a snippet
+// from its title, e.g. "Hello World!" becomes "hello-world".
+func downloadBaseName(snippet models.Snippet) string {
+	var b strings.Builder
+
+	dash := false
+	for _, c := range strings.ToLower(snippet.Title) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	name := strings.Trim(b.String(), "-")
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+
+	return name
+}
+
+// fileFilename returns the name a file is downloaded as: its own name if it
+// has one, otherwise one made up from the snippet and the file's position.
+func fileFilename(snippet models.Snippet, file models.File) string {
+	if file.Name != "" {
+		return file.Name
+	}
+
+	ext := highlight.Lookup(file.Language).Ext
+
+	if file.Position == 0 {
+		return downloadBaseName(snippet) + "." + ext
+	}
+
+	return fmt.Sprintf("file-%d.%s", file.Number(), ext)
+}
+
+func (s *SnippetHandler) SnippetSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	if page > maxSearchPage {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Query = query
+
+	if query != "" {
+		// Fetch one extra row so we know whether there's a next page.
+		snippets, err := s.App.Snippets.Search(query, s.App.AuthenticatedUserID(r), searchPageSize+1, (page-1)*searchPageSize)
+		if err != nil {
+			s.App.ServerError(w, r, err)
+			return
+		}
+
+		pageURL := func(p int) string {
+			return "/snippet/search?" + url.Values{"q": {query}, "page": {strconv.Itoa(p)}}.Encode()
+		}
+
+		if len(snippets) > searchPageSize {
+			snippets = snippets[:searchPageSize]
+			data.Pagination.NextURL = pageURL(page + 1)
+		}
+		if page > 1 {
+			data.Pagination.PrevURL = pageURL(page - 1)
+		}
+
+		data.Snippets = snippets
+	}
+
+	s.App.Render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
 func (s *SnippetHandler) SnippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := s.App.NewTemplateData(r)
 	data.Form = snippetCreateForm{
-		Expires: 7,
+		Files:      []snippetFileForm{{}},
+		Format:     models.FormatCode,
+		Expires:    "1w",
+		Visibility: models.VisibilityPublic,
+	}
+
+	s.App.Render(w, r, http.StatusOK, "create.tmpl", data)
+}
+
+// SnippetFork shows the create form filled in with a copy of a snippet, which
+// is recorded as the new snippet's source when it's saved.
+func (s *SnippetHandler) SnippetFork(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.unlimitedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := s.App.NewTemplateData(r)
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Files:      fileForms(snippet.Files),
+		Format:     snippet.Format,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: models.VisibilityPublic,
+		Expires:    "1w",
+		ForkedFrom: snippet.ID,
 	}
 
 	s.App.Render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 func (s *SnippetHandler) SnippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	r.Body = http.MaxBytesReader(w, r.Body, maxSnippetFormBytes)
 
 	err := r.ParseForm()
 	if err != nil {
@@ -83,13 +942,14 @@ func (s *SnippetHandler) SnippetCreatePost(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Content, 10), "content", "This field must be at least 10 characters long")
-	form.CheckField(validator.MinWordCount(form.Content, 5), "content", "This field must contain at least 5 words")
-	form.CheckField(validator.MaxChars(form.Content, 1000), "content", "This field must be less than 1000 characters long")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	if form.prepareFiles() {
+		data := s.App.NewTemplateData(r)
+		data.Form = form
+		s.App.Render(w, r, http.StatusOK, "create.tmpl", data)
+		return
+	}
+
+	form.validate()
 
 	if !form.Valid() {
 		data := s.App.NewTemplateData(r)
@@ -98,7 +958,21 @@ func (s *SnippetHandler) SnippetCreatePost(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	id, err := s.App.Snippets.Insert(form.Title, form.Content, form.Expires)
+	form.ForkedFrom, err = forkSource(s.App, r, form.ForkedFrom)
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	snippet, err := form.snippet()
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	snippet.UserID = s.App.AuthenticatedUserID(r)
+
+	err = s.App.Snippets.Insert(&snippet)
 	if err != nil {
 		s.App.ServerError(w, r, err)
 		return
@@ -106,5 +980,124 @@ func (s *SnippetHandler) SnippetCreatePost(w http.ResponseWriter, r *http.Reques
 
 	s.App.SessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// ownedSnippet is like viewableSnippet but also requires the snippet to
+// belong to the current user, responding with a 403 if it doesn't.
+func (s *SnippetHandler) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := s.viewableSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if snippet.UserID != s.App.AuthenticatedUserID(r) {
+		s.App.ClientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+func (s *SnippetHandler) SnippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	expires, expiresAt := keepExpiry(snippet)
+
+	data := s.App.NewTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Files:      fileForms(snippet.Files),
+		Format:     snippet.Format,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: snippet.Visibility,
+		Expires:    expires,
+		ExpiresAt:  expiresAt,
+		MaxViews:   snippet.MaxViews,
+	}
+
+	s.App.Render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (s *SnippetHandler) SnippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSnippetFormBytes)
+
+	var form snippetCreateForm
+	form.edit(snippet)
+
+	err := s.App.DecodePostForm(r, &form)
+	if err != nil {
+		s.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.prepareFiles() {
+		data := s.App.NewTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		s.App.Render(w, r, http.StatusOK, "edit.tmpl", data)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := s.App.NewTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		s.App.Render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	updated, err := form.snippet()
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	updated.ID = snippet.ID
+
+	err = s.App.Snippets.Update(updated, s.App.AuthenticatedUserID(r))
+	if err != nil {
+		s.App.ServerError(w, r, err)
+		return
+	}
+
+	s.App.PublishEvent(snippet.ID, live.EventUpdated)
+
+	s.App.SessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+func (s *SnippetHandler) SnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := s.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.App.Snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			s.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	s.App.PublishEvent(snippet.ID, live.EventDeleted)
+
+	s.App.SessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
 }
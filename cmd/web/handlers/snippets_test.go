@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"github.com/go-playground/form/v4"
+	"net/url"
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"thabomoyo.co.uk/internal/models"
+	"time"
+)
+
+func TestFileFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		snippet models.Snippet
+		file    models.File
+		want    string
+	}{
+		{
+			name:    "Title and language",
+			snippet: models.Snippet{ID: 1, Title: "Hello, World!"},
+			file:    models.File{Language: "go"},
+			want:    "hello-world.go",
+		},
+		{
+			name:    "Unknown language",
+			snippet: models.Snippet{ID: 1, Title: "An old silent pond"},
+			file:    models.File{Language: "cobol"},
+			want:    "an-old-silent-pond.txt",
+		},
+		{
+			name:    "No usable characters",
+			snippet: models.Snippet{ID: 42, Title: "¡¿?!"},
+			file:    models.File{Language: "python"},
+			want:    "snippet-42.py",
+		},
+		{
+			name:    "Named file",
+			snippet: models.Snippet{ID: 1, Title: "Hello, World!"},
+			file:    models.File{Position: 1, Name: "main_test.go", Language: "go"},
+			want:    "main_test.go",
+		},
+		{
+			name:    "Unnamed second file",
+			snippet: models.Snippet{ID: 1, Title: "Hello, World!"},
+			file:    models.File{Position: 1, Language: "go"},
+			want:    "file-2.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fileFilename(tt.snippet, tt.file), tt.want)
+		})
+	}
+}
+
+func TestSnippetCreateFormExpiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		expires   string
+		expiresAt string
+		wantField string
+		wantNever bool
+	}{
+		{name: "Preset", expires: "1h"},
+		{name: "Never", expires: "never", wantNever: true},
+		{name: "Custom form layout", expires: "custom", expiresAt: now.AddDate(0, 0, 3).Format("2006-01-02T15:04")},
+		{name: "Custom RFC 3339", expires: "custom", expiresAt: now.AddDate(0, 2, 0).Format(time.RFC3339)},
+		{name: "Unknown choice", expires: "7", wantField: "expires"},
+		{name: "Custom missing", expires: "custom", wantField: "expires_at"},
+		{name: "Custom in the past", expires: "custom", expiresAt: now.Add(-time.Hour).Format(time.RFC3339), wantField: "expires_at"},
+		{name: "Custom too soon", expires: "custom", expiresAt: now.Add(5 * time.Minute).Format(time.RFC3339), wantField: "expires_at"},
+		{name: "Custom too far", expires: "custom", expiresAt: now.AddDate(2, 0, 0).Format(time.RFC3339), wantField: "expires_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:      "An old silent pond",
+				Files:      []snippetFileForm{{Content: "A frog jumps into the pond, splash! Silence again."}},
+				Visibility: models.VisibilityPublic,
+				Expires:    tt.expires,
+				ExpiresAt:  tt.expiresAt,
+			}
+
+			form.validate()
+
+			for _, field := range []string{"expires", "expires_at"} {
+				_, failed := form.FieldErrors[field]
+				assert.Equal(t, failed, field == tt.wantField)
+			}
+
+			if tt.wantField == "" {
+				assert.Equal(t, form.expires.IsZero(), tt.wantNever)
+			}
+		})
+	}
+}
+
+func TestSnippetEditFormKeepsExpiry(t *testing.T) {
+	t.Parallel()
+
+	// Five minutes from expiry, and between whole seconds, so any rounding
+	// would show.
+	current := time.Now().UTC().Add(5*time.Minute + 500*time.Millisecond)
+
+	tests := []struct {
+		name      string
+		editing   bool
+		expires   string
+		expiresAt string
+		wantField string
+	}{
+		{name: "Keep", editing: true, expires: "keep"},
+		{name: "Same custom expiry sent back", editing: true, expires: "custom", expiresAt: current.Format(time.RFC3339Nano)},
+		{name: "New custom expiry too soon", editing: true, expires: "custom", expiresAt: current.Add(time.Minute).Format(time.RFC3339), wantField: "expires_at"},
+		{name: "Keep on a new snippet", expires: "keep", wantField: "expires"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:      "An old silent pond",
+				Files:      []snippetFileForm{{Content: "A frog jumps into the pond, splash! Silence again."}},
+				Visibility: models.VisibilityPublic,
+				Expires:    tt.expires,
+				ExpiresAt:  tt.expiresAt,
+			}
+			if tt.editing {
+				form.edit(models.Snippet{Expires: current})
+			}
+
+			form.validate()
+
+			for _, field := range []string{"expires", "expires_at"} {
+				_, failed := form.FieldErrors[field]
+				assert.Equal(t, failed, field == tt.wantField)
+			}
+
+			if tt.wantField == "" {
+				assert.Equal(t, form.expires, current)
+			}
+		})
+	}
+}
+
+func TestSnippetEditFormMaxViews(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		editing  bool
+		maxViews int
+		views    int
+		wantErr  bool
+	}{
+		{name: "Above views", editing: true, maxViews: 10, views: 3},
+		{name: "Unlimited", editing: true, maxViews: 0, views: 30},
+		{name: "Equal to views", editing: true, maxViews: 10, views: 10, wantErr: true},
+		{name: "Below views", editing: true, maxViews: 1, views: 3, wantErr: true},
+		{name: "New snippet", maxViews: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:      "An old silent pond",
+				Files:      []snippetFileForm{{Content: "A frog jumps into the pond, splash! Silence again."}},
+				Visibility: models.VisibilityPublic,
+				Expires:    expiresNever,
+				MaxViews:   tt.maxViews,
+			}
+			if tt.editing {
+				form.edit(models.Snippet{Views: tt.views})
+			}
+
+			form.validate()
+
+			_, failed := form.FieldErrors["max_views"]
+			assert.Equal(t, failed, tt.wantErr)
+		})
+	}
+}
+
+func TestSnippetEditFormRemovePassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "Remove"},
+		{name: "Remove and replace", password: "new password", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:          "An old silent pond",
+				Files:          []snippetFileForm{{Content: "A frog jumps into the pond, splash! Silence again."}},
+				Visibility:     models.VisibilityPublic,
+				Expires:        expiresNever,
+				Password:       tt.password,
+				RemovePassword: true,
+			}
+			form.edit(models.Snippet{Protected: true})
+
+			form.validate()
+
+			_, failed := form.FieldErrors["password"]
+			assert.Equal(t, failed, tt.wantErr)
+
+			if !tt.wantErr {
+				snippet, err := form.snippet()
+				assert.Equal(t, err, nil)
+				assert.Equal(t, snippet.RemovePassword, true)
+				assert.Equal(t, snippet.Protected, false)
+			}
+		})
+	}
+}
+
+func TestSnippetCreateFormTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tags    string
+		wantErr bool
+	}{
+		{name: "None", tags: ""},
+		{name: "Several", tags: "go, sql, web-dev"},
+		{name: "Mixed case", tags: "Go,SQL"},
+		{name: "Duplicates count once", tags: "a,b,c,d,e,a"},
+		{name: "Too many", tags: "a,b,c,d,e,f", wantErr: true},
+		{name: "Too long", tags: strings.Repeat("a", 31), wantErr: true},
+		{name: "Bad characters", tags: "c++", wantErr: true},
+		{name: "Spaces inside", tags: "web dev", wantErr: true},
+		{name: "Leading hyphen", tags: "-go", wantErr: true},
+		{name: "Double hyphen", tags: "web--dev", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:      "An old silent pond",
+				Files:      []snippetFileForm{{Content: "A frog jumps into the pond, splash! Silence again."}},
+				Tags:       tt.tags,
+				Visibility: models.VisibilityPublic,
+				Expires:    "1w",
+			}
+
+			form.validate()
+
+			_, failed := form.FieldErrors["tags"]
+			assert.Equal(t, failed, tt.wantErr)
+		})
+	}
+}
+
+func TestSnippetCreateFormPrepareFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		values    url.Values
+		wantNames []string
+		wantShow  bool
+	}{
+		{
+			name: "Several files",
+			values: url.Values{
+				"files[0].name": {"main.go"},
+				"files[1].name": {"go.mod"},
+			},
+			wantNames: []string{"main.go", "go.mod"},
+		},
+		{
+			name: "Remove a file",
+			values: url.Values{
+				"files[0].name":   {"main.go"},
+				"files[1].name":   {"go.mod"},
+				"files[1].remove": {"true"},
+			},
+			wantNames: []string{"main.go"},
+		},
+		{
+			name: "Add a file",
+			values: url.Values{
+				"files[0].name": {"main.go"},
+				"add_file":      {"true"},
+			},
+			wantNames: []string{"main.go", ""},
+			wantShow:  true,
+		},
+	}
+
+	decoder := form.NewDecoder()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f snippetCreateForm
+
+			err := decoder.Decode(&f, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, f.prepareFiles(), tt.wantShow)
+			assert.Equal(t, len(f.Files), len(tt.wantNames))
+
+			for i, name := range tt.wantNames {
+				assert.Equal(t, f.Files[i].Name, name)
+			}
+		})
+	}
+}
+
+func TestCommentFormValidate(t *testing.T) {
+	t.Parallel()
+
+	snippet := models.Snippet{Files: []models.File{
+		{Position: 0, Content: "one\ntwo\nthree\n"},
+		{Position: 1, Content: "one"},
+	}}
+
+	tests := []struct {
+		name      string
+		form      commentForm
+		wantField string
+		wantFile  int
+	}{
+		{name: "Whole snippet", form: commentForm{Body: "Looks good"}},
+		{name: "File without line", form: commentForm{Body: "Looks good", File: 2}, wantFile: 0},
+		{name: "Line of first file", form: commentForm{Body: "Typo", Line: 3}, wantFile: 1},
+		{name: "Line of second file", form: commentForm{Body: "Typo", File: 2, Line: 1}, wantFile: 2},
+		{name: "Blank body", form: commentForm{Body: "  "}, wantField: "body"},
+		{name: "Body too long", form: commentForm{Body: strings.Repeat("a", maxCommentChars+1)}, wantField: "body"},
+		{name: "Line past the end", form: commentForm{Body: "Typo", Line: 4}, wantField: "line", wantFile: 1},
+		{name: "Negative line", form: commentForm{Body: "Typo", Line: -1}, wantField: "line", wantFile: 1},
+		{name: "No such file", form: commentForm{Body: "Typo", File: 3, Line: 1}, wantField: "file", wantFile: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := tt.form
+
+			form.validate(snippet)
+
+			for _, field := range []string{"body", "file", "line"} {
+				_, failed := form.FieldErrors[field]
+				assert.Equal(t, failed, field == tt.wantField)
+			}
+			assert.Equal(t, form.File, tt.wantFile)
+		})
+	}
+}
+
+func TestSnippetCreateFormFormatLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		format   string
+		language string
+		want     string
+	}{
+		{name: "Code keeps its language", format: models.FormatCode, language: "go", want: "go"},
+		{name: "Plain text ignores the language", format: models.FormatPlain, language: "go", want: "plaintext"},
+		{name: "Markdown defaults to markdown", format: models.FormatMarkdown, want: "markdown"},
+		{name: "Markdown keeps a chosen language", format: models.FormatMarkdown, language: "yaml", want: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetCreateForm{
+				Title:  "An old silent pond",
+				Files:  []snippetFileForm{{Language: tt.language, Content: "A frog jumps into the pond, splash! Silence again."}},
+				Format: tt.format,
+			}
+
+			snippet, err := form.snippet()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, snippet.Format, tt.format)
+			assert.Equal(t, snippet.Files[0].Language, tt.want)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"thabomoyo.co.uk/cmd/web/config"
 	"thabomoyo.co.uk/internal/models"
 	"thabomoyo.co.uk/internal/validator"
@@ -21,6 +22,11 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+type apiTokenForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
 type UserHandler struct {
 	App *config.Application
 }
@@ -140,24 +146,129 @@ func (u *UserHandler) UserLogoutPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *UserHandler) UserAccountView(w http.ResponseWriter, r *http.Request) {
+	data, ok := u.accountData(w, r)
+	if !ok {
+		return
+	}
+
+	data.Form = apiTokenForm{}
+
+	u.App.Render(w, r, http.StatusOK, "account.tmpl", data)
+}
+
+// starredListSize is the most starred snippets listed on the account page.
+const starredListSize = 50
+
+// accountData loads the current user, their API tokens and the snippets
+// they've starred for the account page. It returns false if a response has
+// already been written.
+func (u *UserHandler) accountData(w http.ResponseWriter, r *http.Request) (config.TemplateData, bool) {
 	data := u.App.NewTemplateData(r)
 
-	id := u.App.SessionManager.Get(r.Context(), "authenticatedUserID")
+	id := u.App.AuthenticatedUserID(r)
 
-	user, err := u.App.Users.Get(id.(int))
+	user, err := u.App.Users.Get(id)
 
 	if err != nil {
 		if errors.Is(models.ErrNoRecord, err) {
 			u.App.SessionManager.Put(r.Context(), "flash", "User not found")
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return data, false
+		}
+
+		u.App.ServerError(w, r, err)
+		return data, false
+	}
+
+	tokens, err := u.App.Tokens.ByUser(id)
+	if err != nil {
+		u.App.ServerError(w, r, err)
+		return data, false
+	}
+
+	starred, err := u.App.Stars.ByUser(id, starredListSize)
+	if err != nil {
+		u.App.ServerError(w, r, err)
+		return data, false
+	}
+
+	data.User = user
+	data.Tokens = tokens
+	data.StarredSnippets = starred
+
+	return data, true
+}
+
+func (u *UserHandler) APITokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenForm
+
+	err := u.App.DecodePostForm(r, &form)
+	if err != nil {
+		u.App.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 50), "name", "This field cannot be more than 50 characters long")
+
+	if !form.Valid() {
+		data, ok := u.accountData(w, r)
+		if !ok {
 			return
 		}
+		data.Form = form
+		u.App.Render(w, r, http.StatusUnprocessableEntity, "account.tmpl", data)
+		return
+	}
 
+	token, err := u.App.Tokens.Insert(u.App.AuthenticatedUserID(r), form.Name)
+	if err != nil {
 		u.App.ServerError(w, r, err)
 		return
 	}
 
-	data.User = user
+	// The token is only ever shown in this response, so it's never stored in
+	// the session where it would outlive the page.
+	data, ok := u.accountData(w, r)
+	if !ok {
+		return
+	}
+	data.Form = apiTokenForm{}
+	data.NewToken = token
+	data.Flash = "API token created. Copy it now, it won't be shown again."
 
 	u.App.Render(w, r, http.StatusOK, "account.tmpl", data)
 }
+
+func (u *UserHandler) APITokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = u.App.Tokens.Delete(id, u.App.AuthenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			u.App.ServerError(w, r, err)
+		}
+		return
+	}
+
+	u.App.SessionManager.Put(r.Context(), "flash", "API token revoked.")
+
+	http.Redirect(w, r, "/user/account/view", http.StatusSeeOther)
+}
+
+func (u *UserHandler) UserSnippetsView(w http.ResponseWriter, r *http.Request) {
+	data := u.App.NewTemplateData(r)
+
+	base := models.ListOptions{UserID: u.App.AuthenticatedUserID(r), AllVisibilities: true}
+	if !listSnippets(u.App, w, r, "/user/snippets", base, &data) {
+		return
+	}
+
+	u.App.Render(w, r, http.StatusOK, "user_snippets.tmpl", data)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/cmd/web/routes"
+	"thabomoyo.co.uk/internal/assert"
+	"thabomoyo.co.uk/internal/models/mocks"
+)
+
+func TestSnippetOwnerOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{name: "Owner", email: "alice@example.com", wantCode: http.StatusOK},
+		{name: "Another user", email: "bob@example.com", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, routes.Routes(newTestApplication(t)))
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+
+			code, _, _ := ts.get(t, "/snippet/edit/1")
+			assert.Equal(t, code, tt.wantCode)
+
+			csrfToken := ts.csrfToken(t, "/snippet/create")
+
+			form := url.Values{
+				"csrf_token":        {csrfToken},
+				"title":             {"Public pond"},
+				"files[0].content":  {"An old silent pond, a frog jumps into the pond."},
+				"files[0].language": {""},
+				"format":            {"plain"},
+				"visibility":        {"public"},
+				"expires":           {"never"},
+				"max_views":         {"0"},
+			}
+
+			wantPost := tt.wantCode
+			if wantPost == http.StatusOK {
+				wantPost = http.StatusSeeOther
+			}
+
+			code, _, _ = ts.postForm(t, "/snippet/edit/1", form)
+			assert.Equal(t, code, wantPost)
+
+			code, _, _ = ts.postForm(t, "/snippet/delete/1", url.Values{"csrf_token": {csrfToken}})
+			assert.Equal(t, code, wantPost)
+		})
+	}
+}
+
+func TestSnippetViewPrivate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{name: "Anonymous by ID", urlPath: "/snippet/view/3", wantCode: http.StatusNotFound},
+		{name: "Anonymous by slug", urlPath: "/s/PrivatePond3", wantCode: http.StatusNotFound},
+		{name: "Anonymous raw", urlPath: "/s/PrivatePond3/raw", wantCode: http.StatusNotFound},
+		{name: "Another user", email: "bob@example.com", urlPath: "/s/PrivatePond3", wantCode: http.StatusNotFound},
+		{name: "Owner", email: "alice@example.com", urlPath: "/s/PrivatePond3", wantCode: http.StatusOK},
+		{name: "Anonymous public", urlPath: "/snippet/view/1", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, routes.Routes(newTestApplication(t)))
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestListingsLeaveOutUnlisted(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t, routes.Routes(newTestApplication(t)))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		urlPath string
+		token   string
+	}{
+		{name: "Home", urlPath: "/"},
+		{name: "Search", urlPath: "/snippet/search?q=pond"},
+		{name: "API", urlPath: "/api/v1/snippets", token: mocks.MockToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rs, err := ts.Client().Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, _, body := readResponse(t, rs)
+
+			assert.Equal(t, code, http.StatusOK)
+			// Search results highlight the query in titles, so look for the
+			// slugs instead.
+			assert.Equal(t, strings.Contains(body, "PublicPond01"), true)
+			assert.Equal(t, strings.Contains(body, "UnlistedPnd2"), false)
+			assert.Equal(t, strings.Contains(body, "PrivatePond3"), false)
+		})
+	}
+}
+
+func TestAPITokenCreatePost(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t, routes.Routes(newTestApplication(t)))
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+
+	form := url.Values{
+		"name":       {"laptop"},
+		"csrf_token": {ts.csrfToken(t, "/user/account/view")},
+	}
+
+	code, _, body := ts.postForm(t, "/user/account/tokens", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, mocks.MockToken), true)
+
+	// The token is only shown in the response that created it.
+	_, _, body = ts.get(t, "/user/account/view")
+	assert.Equal(t, strings.Contains(body, mocks.MockToken), false)
+}
+
+func TestSnippetSearchPage(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t, routes.Routes(newTestApplication(t)))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		page     string
+		wantCode int
+	}{
+		{name: "First", page: "1", wantCode: http.StatusOK},
+		{name: "Invalid", page: "nope", wantCode: http.StatusOK},
+		{name: "Last", page: "1000", wantCode: http.StatusOK},
+		{name: "Past the last", page: "1001", wantCode: http.StatusBadRequest},
+		{name: "Overflowing", page: "9223372036854775807", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, "/snippet/search?q=pond&page="+tt.page)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
@@ -11,14 +12,18 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
 	"thabomoyo.co.uk/cmd/web/config"
 	"thabomoyo.co.uk/cmd/web/routes"
+	"thabomoyo.co.uk/internal/live"
 	"thabomoyo.co.uk/internal/models"
 )
 
@@ -30,6 +35,10 @@ func main() {
 	port := flag.Int("port", 8888, "Port to run the server on")
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	reapInterval := flag.Duration("reap-interval", time.Minute, "How often to delete expired snippets")
+	ticketSecret := flag.String("ticket-secret", "", "Secret shared with the WebSocket server for signing tickets")
+	notifyURL := flag.String("notify-url", "", "Publish endpoint of the notification server, e.g. http://localhost:8889/publish")
+	publishSecret := flag.String("publish-secret", "", "Secret shared with the notification server for publishing")
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
@@ -54,13 +63,28 @@ func main() {
 	sessionManager.Lifetime = 15 * time.Minute
 	sessionManager.Cookie.Secure = true
 
+	var notifier *live.Publisher
+	if *notifyURL != "" {
+		if *publishSecret == "" {
+			logger.Error("a -publish-secret is required with -notify-url")
+			os.Exit(1)
+		}
+		notifier = live.NewPublisher(*notifyURL, []byte(*publishSecret), logger)
+	}
+
 	app := config.Application{
 		Logger:         logger,
 		Snippets:       &models.SnippetModel{DB: db},
 		Users:          &models.UserModel{DB: db},
+		Tokens:         &models.TokenModel{DB: db},
+		Stars:          &models.StarModel{DB: db},
+		Comments:       &models.CommentModel{DB: db},
+		Live:           live.NewHub(logger),
+		Notifier:       notifier,
 		TemplateCache:  templateCache,
 		FormDecoder:    form.NewDecoder(),
 		SessionManager: &sessionManager,
+		TicketSecret:   []byte(*ticketSecret),
 		DebugMode:      *debug,
 	}
 
@@ -101,9 +125,55 @@ func main() {
 		MaxHeaderBytes: 524288,
 	}
 
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	logger.Error(err.Error())
-	os.Exit(1)
+	// Stop on SIGINT or SIGTERM, letting in-flight requests and the reaper
+	// finish first.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reapExpiredSnippets(ctx, app.Snippets, logger, *reapInterval)
+	}()
+
+	// Shutdown doesn't wait for hijacked connections, so the hub closes its
+	// WebSockets itself when ctx is cancelled.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.Live.Run(ctx)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	}()
+
+	select {
+	case err = <-serveErr:
+		logger.Error(err.Error())
+		stop()
+		wg.Wait()
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	wg.Wait()
+
+	if err != nil {
+		logger.Error("Server shutdown failed: " + err.Error())
+		os.Exit(1)
+	}
+
+	logger.Info("server stopped")
 }
 
 func openDB(dsn string) (*sql.DB, error) {
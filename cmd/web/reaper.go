@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// reapBatchSize is the most expired snippets deleted by one statement.
+const reapBatchSize = 500
+
+type expiredSnippetDeleter interface {
+	DeleteExpired(limit int) (int, error)
+}
+
+// reapExpiredSnippets deletes expired snippets every interval until ctx is
+// cancelled. Each run deletes batch after batch until there's nothing left,
+// so a backlog is cleared in one go without holding long locks.
+func reapExpiredSnippets(ctx context.Context, snippets expiredSnippetDeleter, logger *slog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reapOnce(ctx, snippets, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func reapOnce(ctx context.Context, snippets expiredSnippetDeleter, logger *slog.Logger) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := snippets.DeleteExpired(reapBatchSize)
+		if err != nil {
+			logger.Error("reaping expired snippets failed", slog.Any("err", err), slog.Int("deleted", total))
+			return
+		}
+
+		total += n
+
+		if n < reapBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("reaped expired snippets", slog.Int("deleted", total))
+	}
+}
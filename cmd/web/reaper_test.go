@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"time"
+)
+
+// stubDeleter pretends there are expired snippets to delete, failing
+// with err once they've all gone if it's set.
+type stubDeleter struct {
+	expired int
+	calls   int
+	err     error
+}
+
+func (d *stubDeleter) DeleteExpired(limit int) (int, error) {
+	d.calls++
+
+	if d.expired == 0 && d.err != nil {
+		return 0, d.err
+	}
+
+	n := min(limit, d.expired)
+	d.expired -= n
+
+	return n, nil
+}
+
+func TestReapOnce(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		expired   int
+		err       error
+		wantCalls int
+	}{
+		{name: "Nothing expired", expired: 0, wantCalls: 1},
+		{name: "Single batch", expired: reapBatchSize - 1, wantCalls: 1},
+		{name: "Exactly one batch", expired: reapBatchSize, wantCalls: 2},
+		{name: "Several batches", expired: 2*reapBatchSize + 1, wantCalls: 3},
+		{name: "Error stops the run", expired: reapBatchSize, err: errors.New("boom"), wantCalls: 2},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &stubDeleter{expired: tt.expired, err: tt.err}
+
+			reapOnce(context.Background(), d, logger)
+
+			assert.Equal(t, d.expired, 0)
+			assert.Equal(t, d.calls, tt.wantCalls)
+		})
+	}
+}
+
+func TestReapExpiredSnippetsStops(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		reapExpiredSnippets(ctx, &stubDeleter{}, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Hour)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper didn't stop after its context was cancelled")
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/justinas/alice"
+	"net/http"
+	"thabomoyo.co.uk/cmd/web/handlers"
+)
+
+// APIRoutes registers the JSON API, authenticated by session or API token. It
+// skips noSurf, so writes instead insist on an application/json body, which a
+// cross-site form can't send.
+func (route *RouteResource) APIRoutes(mux *http.ServeMux) http.Handler {
+	protected := alice.New(route.app.SessionManager.LoadAndSave, route.authenticateToken, route.authenticate, route.requireAPIAuthentication)
+
+	apiResource := &handlers.APIHandler{
+		App: route.app,
+	}
+
+	mux.Handle("GET /api/v1/snippets", protected.ThenFunc(apiResource.SnippetList))
+	mux.Handle("POST /api/v1/snippets", protected.ThenFunc(apiResource.SnippetCreate))
+	mux.Handle("GET /api/v1/snippets/{id}", protected.ThenFunc(apiResource.SnippetGet))
+	mux.Handle("PUT /api/v1/snippets/{id}", protected.ThenFunc(apiResource.SnippetUpdate))
+	mux.Handle("DELETE /api/v1/snippets/{id}", protected.ThenFunc(apiResource.SnippetDelete))
+	mux.Handle("POST /api/v1/ws-tickets", protected.ThenFunc(apiResource.TicketCreate))
+
+	return mux
+}
@@ -2,10 +2,13 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
 	"net/http"
+	"strings"
 	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/models"
 )
 
 func commonHeaders(next http.Handler) http.Handler {
@@ -75,6 +78,22 @@ func (route *RouteResource) requireAuthentication(next http.Handler) http.Handle
 	})
 }
 
+/**
+ * API counterpart of requireAuthentication, responding with a JSON 401 instead of redirecting to the login page.
+ */
+func (route *RouteResource) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !route.app.IsAuthenticated(r) {
+			route.app.ClientErrorJSON(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // CSRF protection middleware
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -87,6 +106,28 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+/**
+ * Loads the session from its cookie without wrapping the response writer, for WebSocket routes: LoadAndSave's
+ * wrapper can't be hijacked. Nothing changed in the session is saved.
+ */
+func (route *RouteResource) loadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		cookie, err := r.Cookie(route.app.SessionManager.Cookie.Name)
+		if err == nil {
+			token = cookie.Value
+		}
+
+		ctx, err := route.app.SessionManager.Load(r.Context(), token)
+		if err != nil {
+			route.app.ServerError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (route *RouteResource) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := route.app.SessionManager.GetInt(r.Context(), "authenticatedUserID")
@@ -103,9 +144,50 @@ func (route *RouteResource) authenticate(next http.Handler) http.Handler {
 
 		if exists {
 			ctx := context.WithValue(r.Context(), config.IsAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, config.AuthenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+/**
+ * Authenticates requests carrying an "Authorization: Bearer <token>" header using a personal API token. It's only
+ * used on the API: the HTML pages take sessions alone, so a token can't reach account pages such as token management.
+ * Other schemes, like Basic auth added by a proxy in front of the app, are passed through untouched.
+ */
+func (route *RouteResource) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token = strings.TrimSpace(token)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			route.app.ClientErrorJSON(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		id, err := route.app.Tokens.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				route.app.ClientErrorJSON(w, r, http.StatusUnauthorized)
+			} else {
+				route.app.ServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), config.IsAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, config.AuthenticatedUserIDContextKey, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -3,9 +3,11 @@ package routes
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"thabomoyo.co.uk/cmd/web/config"
 	"thabomoyo.co.uk/internal/assert"
 )
 
@@ -56,3 +58,97 @@ func TestCommonHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRequireAPIAuthentication(t *testing.T) {
+	t.Parallel()
+
+	route := &RouteResource{
+		app: &config.Application{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/api/v1/snippets", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler should not be called")
+	})
+
+	route.requireAPIAuthentication(next).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+
+	assert.Equal(t, rs.StatusCode, http.StatusUnauthorized)
+	assert.Equal(t, rs.Header.Get("Content-Type"), "application/json")
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	assert.Equal(t, string(body), `{"error":{"status":401,"message":"Unauthorized"}}`)
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	t.Parallel()
+
+	route := &RouteResource{
+		app: &config.Application{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+	}{
+		{
+			name:          "No header",
+			authorization: "",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Basic auth from a proxy",
+			authorization: "Basic dXNlcjpwYXNz",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Empty Bearer token",
+			authorization: "Bearer ",
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name:          "Lower-case scheme, empty token",
+			authorization: "bearer",
+			wantCode:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/api/v1/snippets", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				isAuthenticated, _ := r.Context().Value(config.IsAuthenticatedContextKey).(bool)
+				assert.Equal(t, isAuthenticated, false)
+				w.Write([]byte("OK"))
+			})
+
+			route.authenticateToken(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
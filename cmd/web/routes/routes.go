@@ -36,6 +36,7 @@ func Routes(app *config.Application) http.Handler {
 
 	mux.Handle("/", routeResources.SnippetRoutes(mux))
 	mux.Handle("/user/", http.StripPrefix("/user", routeResources.UserRoutes(mux)))
+	routeResources.APIRoutes(mux)
 
 	standard := alice.New(routeResources.recoverPanic, routeResources.logRequest, commonHeaders)
 	return standard.Then(mux)
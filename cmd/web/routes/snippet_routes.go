@@ -7,16 +7,50 @@ import (
 )
 
 func (route *RouteResource) SnippetRoutes(mux *http.ServeMux) http.Handler {
-	protected := alice.New(route.app.SessionManager.LoadAndSave, noSurf, route.authenticate, route.requireAuthentication)
+	dynamic := alice.New(route.app.SessionManager.LoadAndSave, noSurf, route.authenticate)
+	protected := dynamic.Append(route.requireAuthentication)
+	live := alice.New(route.loadSession, route.authenticate)
 
 	snippetResource := &handlers.SnippetHandler{
 		App: route.app,
 	}
 
-	mux.Handle("GET /{$}", protected.ThenFunc(snippetResource.Home))
-	mux.Handle("GET /snippet/view/{id}", protected.ThenFunc(snippetResource.SnippetView))
+	// Reads are open to anonymous users, who can see public and unlisted
+	// snippets. Private snippets are filtered out by the handlers.
+	mux.Handle("GET /{$}", dynamic.ThenFunc(snippetResource.Home))
+	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(snippetResource.SnippetView))
+	mux.Handle("GET /snippet/raw/{id}", dynamic.ThenFunc(snippetResource.SnippetRaw))
+	mux.Handle("GET /snippet/download/{id}", dynamic.ThenFunc(snippetResource.SnippetDownload))
+	mux.Handle("GET /snippet/search", dynamic.ThenFunc(snippetResource.SnippetSearch))
+	mux.Handle("GET /tag/{name}", dynamic.ThenFunc(snippetResource.SnippetTag))
+	mux.Handle("GET /popular", dynamic.ThenFunc(snippetResource.SnippetPopular))
+	mux.Handle("GET /snippet/history/{id}", dynamic.ThenFunc(snippetResource.SnippetHistory))
+	mux.Handle("GET /snippet/diff/{id}", dynamic.ThenFunc(snippetResource.SnippetDiff))
+
+	// Share links. Unlike the numeric routes above these reach unlisted
+	// snippets, since the slug can't be guessed.
+	mux.Handle("GET /s/{slug}", dynamic.ThenFunc(snippetResource.SnippetView))
+	mux.Handle("GET /s/{slug}/raw", dynamic.ThenFunc(snippetResource.SnippetRaw))
+	mux.Handle("GET /s/{slug}/raw/{file}", dynamic.ThenFunc(snippetResource.SnippetRaw))
+	mux.Handle("GET /s/{slug}/download", dynamic.ThenFunc(snippetResource.SnippetDownload))
+	mux.Handle("GET /s/{slug}/history", dynamic.ThenFunc(snippetResource.SnippetHistory))
+	mux.Handle("GET /s/{slug}/diff", dynamic.ThenFunc(snippetResource.SnippetDiff))
+	mux.Handle("POST /s/{slug}/unlock", dynamic.ThenFunc(snippetResource.SnippetUnlockPost))
+	mux.Handle("POST /s/{slug}/reveal", dynamic.ThenFunc(snippetResource.SnippetRevealPost))
+	mux.Handle("GET /s/{slug}/live", live.ThenFunc(snippetResource.SnippetLive))
+
 	mux.Handle("GET /snippet/create", protected.ThenFunc(snippetResource.SnippetCreate))
 	mux.Handle("POST /snippet/create", protected.ThenFunc(snippetResource.SnippetCreatePost))
+	mux.Handle("GET /s/{slug}/fork", protected.ThenFunc(snippetResource.SnippetFork))
+	mux.Handle("POST /s/{slug}/star", protected.ThenFunc(snippetResource.SnippetStarPost))
+	mux.Handle("POST /s/{slug}/comments", protected.ThenFunc(snippetResource.SnippetCommentPost))
+	mux.Handle("GET /snippet/edit/{id}", protected.ThenFunc(snippetResource.SnippetEdit))
+	mux.Handle("POST /snippet/edit/{id}", protected.ThenFunc(snippetResource.SnippetEditPost))
+	mux.Handle("POST /snippet/delete/{id}", protected.ThenFunc(snippetResource.SnippetDeletePost))
+	mux.Handle("POST /snippet/restore/{id}", protected.ThenFunc(snippetResource.SnippetRestorePost))
+	mux.Handle("GET /comment/edit/{id}", protected.ThenFunc(snippetResource.CommentEdit))
+	mux.Handle("POST /comment/edit/{id}", protected.ThenFunc(snippetResource.CommentEditPost))
+	mux.Handle("POST /comment/delete/{id}", protected.ThenFunc(snippetResource.CommentDeletePost))
 
 	return mux
 }
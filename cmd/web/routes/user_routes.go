@@ -20,10 +20,13 @@ func (route *RouteResource) UserRoutes(mux *http.ServeMux) http.Handler {
 	mux.Handle("GET /signup", dynamic.ThenFunc(userResource.UserSignup))
 	mux.Handle("GET /login", dynamic.ThenFunc(userResource.UserLogin))
 	mux.Handle("GET /account/view", protected.ThenFunc(userResource.UserAccountView))
+	mux.Handle("GET /snippets", protected.ThenFunc(userResource.UserSnippetsView))
 
 	mux.Handle("POST /login", dynamic.ThenFunc(userResource.UserLoginPost))
 	mux.Handle("POST /signup", dynamic.ThenFunc(userResource.UserSignupPost))
 	mux.Handle("POST /logout", dynamic.ThenFunc(userResource.UserLogoutPost))
+	mux.Handle("POST /account/tokens", protected.ThenFunc(userResource.APITokenCreatePost))
+	mux.Handle("POST /account/tokens/revoke/{id}", protected.ThenFunc(userResource.APITokenRevokePost))
 
 	return mux
 }
@@ -4,9 +4,13 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"thabomoyo.co.uk/ui"
 	"time"
+	"unicode/utf8"
 
+	"thabomoyo.co.uk/internal/highlight"
+	"thabomoyo.co.uk/internal/markdown"
 	"thabomoyo.co.uk/internal/models"
 )
 
@@ -18,6 +22,94 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// searchTerms splits a search query into the lower-cased words to highlight,
+// dropping the MySQL full-text operators that may surround them.
+func searchTerms(query string) []string {
+	var terms []string
+
+	for _, f := range strings.Fields(query) {
+		f = strings.Trim(f, `+-<>()~*"`)
+		if f != "" {
+			terms = append(terms, strings.ToLower(f))
+		}
+	}
+
+	return terms
+}
+
+// highlightMatches HTML-escapes text and wraps every case-insensitive occurrence of
+// the query's terms in a <mark> element.
+func highlightMatches(text, query string) template.HTML {
+	terms := searchTerms(query)
+	lower := strings.ToLower(text)
+
+	// Bail out if lower-casing changed byte offsets, since the matches found
+	// in lower would no longer line up with text.
+	if len(terms) == 0 || len(lower) != len(text) {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		start, end := -1, -1
+		for _, term := range terms {
+			j := strings.Index(lower[i:], term)
+			if j >= 0 && (start == -1 || i+j < start || (i+j == start && i+j+len(term) > end)) {
+				start, end = i+j, i+j+len(term)
+			}
+		}
+
+		if start == -1 {
+			b.WriteString(template.HTMLEscapeString(text[i:]))
+			break
+		}
+
+		b.WriteString(template.HTMLEscapeString(text[i:start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[start:end]))
+		b.WriteString("</mark>")
+		i = end
+	}
+
+	return template.HTML(b.String())
+}
+
+// excerpt returns roughly n characters of text centred on the first match of
+// the query's terms, with ellipses marking anything cut off.
+func excerpt(text, query string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	match := 0
+	if len(lower) == len(runes) {
+		for _, term := range searchTerms(query) {
+			if i := strings.Index(string(lower), term); i >= 0 {
+				match = utf8.RuneCountInString(string(lower)[:i])
+				break
+			}
+		}
+	}
+
+	start := max(0, match-n/4)
+	end := min(len(runes), start+n)
+	start = max(0, end-n)
+
+	out := string(runes[start:end])
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+
+	return out
+}
+
 type templateData struct {
 	Snippet         models.Snippet
 	Snippets        []models.Snippet
@@ -29,8 +121,20 @@ type templateData struct {
 	CSRFToken       string // Add a CSRFToken field.
 }
 
+// highlightLines highlights one file of a snippet with numbered lines that
+// comments can be anchored to.
+func highlightLines(content, language string, file int) (template.HTML, error) {
+	return highlight.HTMLWithLines(content, language, models.LinePrefix(file))
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":        humanDate,
+	"highlightMatches": highlightMatches,
+	"excerpt":          excerpt,
+	"highlightLines":   highlightLines,
+	"renderMarkdown":   markdown.HTML,
+	"languageName":     highlight.Name,
+	"languages":        func() []highlight.Language { return highlight.Languages },
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
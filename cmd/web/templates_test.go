@@ -40,3 +40,45 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlightMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{
+			name:  "No query",
+			text:  "An old silent pond",
+			query: "",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "An old silent pond",
+			query: "OLD",
+			want:  "An <mark>old</mark> silent pond",
+		},
+		{
+			name:  "Multiple terms",
+			text:  "An old silent pond",
+			query: "pond +old",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Escapes HTML",
+			text:  "<b>old</b>",
+			query: "old",
+			want:  "&lt;b&gt;<mark>old</mark>&lt;/b&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(highlightMatches(tt.text, tt.query)), tt.want)
+		})
+	}
+}
@@ -2,18 +2,47 @@ package main
 
 import (
 	"bytes"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"html"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"testing"
 	"thabomoyo.co.uk/cmd/web/config"
+	"thabomoyo.co.uk/internal/live"
+	"thabomoyo.co.uk/internal/models/mocks"
+	"time"
 )
 
+// newTestApplication returns an application backed by the mock models.
 func newTestApplication(t *testing.T) *config.Application {
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 15 * time.Minute
+	sessionManager.Cookie.Secure = true
+
 	return &config.Application{
-		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Logger:         logger,
+		Snippets:       &mocks.SnippetModel{},
+		Users:          &mocks.UserModel{},
+		Tokens:         &mocks.TokenModel{},
+		Stars:          &mocks.StarModel{},
+		Comments:       &mocks.CommentModel{},
+		Live:           live.NewHub(logger),
+		TemplateCache:  templateCache,
+		FormDecoder:    form.NewDecoder(),
+		SessionManager: sessionManager,
 	}
 }
 
@@ -27,6 +56,19 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 		t.Fatal(err)
 	}
 
+	return readResponse(t, rs)
+}
+
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return readResponse(t, rs)
+}
+
+func readResponse(t *testing.T, rs *http.Response) (int, http.Header, string) {
 	defer rs.Body.Close()
 	body, err := io.ReadAll(rs.Body)
 	if err != nil {
@@ -37,6 +79,36 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+?)'>`)
+
+// csrfToken fetches the page at urlPath and returns the CSRF token in its
+// first form.
+func (ts *testServer) csrfToken(t *testing.T, urlPath string) string {
+	_, _, body := ts.get(t, urlPath)
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatalf("no CSRF token found in %s", urlPath)
+	}
+
+	return html.UnescapeString(matches[1])
+}
+
+// login signs in as the mock user with the given email address. Later
+// requests through the server's client are made as them.
+func (ts *testServer) login(t *testing.T, email string) {
+	form := url.Values{
+		"email":      {email},
+		"password":   {mocks.MockPassword},
+		"csrf_token": {ts.csrfToken(t, "/user/login")},
+	}
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in as %s: got status %d", email, code)
+	}
+}
+
 func newTestServer(t *testing.T, h http.Handler) *testServer {
 	// Initialize the test server as normal.
 	ts := httptest.NewTLSServer(h)
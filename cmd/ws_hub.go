@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+// Message types. Clients send subscribe and unsubscribe; the server answers
+// with subscribed, unsubscribed or error, and passes data published through
+// its publish endpoint on to subscribers as message.
+const (
+	typeSubscribe    = "subscribe"
+	typeUnsubscribe  = "unsubscribe"
+	typeSubscribed   = "subscribed"
+	typeUnsubscribed = "unsubscribed"
+	typeMessage      = "message"
+	typeError        = "error"
+)
+
+// message is the JSON sent each way over a connection.
+type message struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// channelRX matches channel names like "snippet:42" or "user.7.stars".
+var channelRX = regexp.MustCompile(`^[a-z0-9]+(?:[:._-][a-z0-9]+)*$`)
+
+func validChannel(channel string) bool {
+	return len(channel) <= maxChannelLength && channelRX.MatchString(channel)
+}
+
+const (
+	// maxChannelLength is the longest a channel name can be.
+	maxChannelLength = 100
+
+	// sendQueueSize is how many messages can be waiting to go out to a
+	// connection before it's considered too slow and disconnected.
+	sendQueueSize = 64
+
+	// maxMessageSize is the largest message a client can send.
+	maxMessageSize = 64 * 1024
+
+	// closeGoingAway is the close code sent when the server shuts down.
+	closeGoingAway = 1001
+)
+
+// closePayload returns the body of a close frame with the given status code
+// and reason.
+func closePayload(code int, reason string) []byte {
+	b := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], reason)
+
+	return b
+}
+
+// wsConn is the part of *websocket.Conn a client writes to.
+type wsConn interface {
+	WriteMessage(messageType websocket.MessageType, data []byte) error
+	Close() error
+}
+
+// client is one connection to the hub. Everything written to it goes through
+// its send queue, which a single goroutine drains, so a slow connection can't
+// hold up publishing to everyone else.
+type client struct {
+	conn   wsConn
+	userID int
+	send   chan []byte
+	bye    chan []byte
+	done   chan struct{}
+	once   sync.Once
+	logger *slog.Logger
+
+	// granted is the set of channels the client's ticket lets it subscribe
+	// to, besides its user's own.
+	granted map[string]bool
+
+	// channels is the set of channels the client is subscribed to. It's
+	// guarded by the hub's mutex.
+	channels map[string]struct{}
+}
+
+func newClient(conn wsConn, userID int, granted []string, logger *slog.Logger) *client {
+	c := &client{
+		conn:     conn,
+		userID:   userID,
+		send:     make(chan []byte, sendQueueSize),
+		bye:      make(chan []byte, 1),
+		done:     make(chan struct{}),
+		logger:   logger,
+		granted:  map[string]bool{},
+		channels: map[string]struct{}{},
+	}
+
+	for _, channel := range granted {
+		c.granted[channel] = true
+	}
+
+	return c
+}
+
+// canSubscribe reports whether the client may subscribe to a channel: one
+// under "user.<id>" or "user:<id>" for its own user, or one its ticket
+// grants. The web app only grants channels it has checked the user can see,
+// so anything else is refused.
+func (c *client) canSubscribe(channel string) bool {
+	if c.granted[channel] {
+		return true
+	}
+
+	segments := strings.FieldsFunc(channel, func(r rune) bool {
+		return strings.ContainsRune(":._-", r)
+	})
+
+	return len(segments) > 1 && segments[0] == "user" && segments[1] == strconv.Itoa(c.userID)
+}
+
+// queue adds data to the send queue. If the queue is full the client isn't
+// keeping up, so it's disconnected and false is returned.
+func (c *client) queue(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	default:
+		c.logger.Warn("send queue full, disconnecting")
+		c.close()
+		return false
+	}
+}
+
+// queueMessage encodes m and adds it to the send queue.
+func (c *client) queueMessage(m message) bool {
+	data, err := json.Marshal(m)
+	if err != nil {
+		c.logger.Error("encoding message failed", slog.Any("err", err))
+		return false
+	}
+
+	return c.queue(data)
+}
+
+// writeLoop writes queued messages to the connection, and pings it every
+// interval, until the client is closed or sent a close frame.
+func (c *client) writeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			err = c.conn.WriteMessage(websocket.TextMessage, data)
+		case <-ticker.C:
+			err = c.conn.WriteMessage(websocket.PingMessage, nil)
+		case payload := <-c.bye:
+			c.flush()
+			// Nothing can be sent after a close frame. The connection is
+			// closed when the client answers it.
+			c.conn.WriteMessage(websocket.CloseMessage, payload)
+			c.stop()
+			return
+		}
+
+		if err != nil {
+			c.logger.Info("write failed, disconnecting", slog.Any("err", err))
+			c.close()
+			return
+		}
+	}
+}
+
+// flush writes whatever is left in the send queue.
+func (c *client) flush() {
+	for {
+		select {
+		case data := <-c.send:
+			err := c.conn.WriteMessage(websocket.TextMessage, data)
+			if err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// goAway sends the client a close frame with the given code and reason,
+// after anything already in its queue.
+func (c *client) goAway(code int, reason string) {
+	select {
+	case c.bye <- closePayload(code, reason):
+	default:
+	}
+}
+
+// stop stops the write loop and any more messages being queued.
+func (c *client) stop() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// close stops the write loop and closes the connection. It's safe to call
+// more than once.
+func (c *client) close() {
+	c.stop()
+	c.conn.Close()
+}
+
+// hub routes published messages to the clients subscribed to each channel.
+type hub struct {
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	channels map[string]map[*client]struct{}
+}
+
+func newHub(logger *slog.Logger) *hub {
+	return &hub{
+		logger:   logger,
+		channels: map[string]map[*client]struct{}{},
+	}
+}
+
+func (h *hub) subscribe(c *client, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.channels[channel] == nil {
+		h.channels[channel] = map[*client]struct{}{}
+	}
+	h.channels[channel][c] = struct{}{}
+	c.channels[channel] = struct{}{}
+}
+
+func (h *hub) unsubscribe(c *client, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribeLocked(c, channel)
+}
+
+func (h *hub) unsubscribeLocked(c *client, channel string) {
+	delete(h.channels[channel], c)
+	if len(h.channels[channel]) == 0 {
+		delete(h.channels, channel)
+	}
+	delete(c.channels, channel)
+}
+
+// remove unsubscribes the client from every channel.
+func (h *hub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for channel := range c.channels {
+		h.unsubscribeLocked(c, channel)
+	}
+}
+
+// subscribers returns the number of clients subscribed to a channel.
+func (h *hub) subscribers(channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.channels[channel])
+}
+
+// publish queues data for every client subscribed to channel, and returns how
+// many it was queued for.
+func (h *hub) publish(channel string, data json.RawMessage) int {
+	encoded, err := json.Marshal(message{Type: typeMessage, Channel: channel, Data: data})
+	if err != nil {
+		h.logger.Error("encoding message failed", slog.Any("err", err))
+		return 0
+	}
+
+	h.mu.Lock()
+	clients := make([]*client, 0, len(h.channels[channel]))
+	for c := range h.channels[channel] {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	delivered := 0
+	for _, c := range clients {
+		if c.queue(encoded) {
+			delivered++
+		}
+	}
+
+	return delivered
+}
+
+// handle acts on a message from a client.
+func (h *hub) handle(c *client, data []byte) {
+	var m message
+
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		c.queueMessage(message{Type: typeError, Error: "message must be a JSON object"})
+		return
+	}
+
+	if !validChannel(m.Channel) {
+		c.queueMessage(message{Type: typeError, Channel: m.Channel, Error: "invalid channel name"})
+		return
+	}
+
+	switch m.Type {
+	case typeSubscribe:
+		if !c.canSubscribe(m.Channel) {
+			c.queueMessage(message{Type: typeError, Channel: m.Channel, Error: "not allowed to subscribe to channel"})
+			return
+		}
+		h.subscribe(c, m.Channel)
+		c.queueMessage(message{Type: typeSubscribed, Channel: m.Channel})
+	case typeUnsubscribe:
+		h.unsubscribe(c, m.Channel)
+		c.queueMessage(message{Type: typeUnsubscribed, Channel: m.Channel})
+	default:
+		c.queueMessage(message{Type: typeError, Channel: m.Channel, Error: "unknown message type"})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"time"
+
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+// fakeConn records the text messages written to it.
+type fakeConn struct {
+	mu       sync.Mutex
+	messages []string
+	closed   bool
+	broken   bool
+}
+
+func (f *fakeConn) WriteMessage(messageType websocket.MessageType, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.broken {
+		return errors.New("broken pipe")
+	}
+	if messageType == websocket.TextMessage {
+		f.messages = append(f.messages, string(data))
+	}
+
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+
+	return nil
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// queued drains and returns what's waiting in a client's send queue.
+func queued(c *client) []string {
+	var messages []string
+	for {
+		select {
+		case data := <-c.send:
+			messages = append(messages, string(data))
+		default:
+			return messages
+		}
+	}
+}
+
+func TestHubHandle(t *testing.T) {
+	t.Parallel()
+
+	h := newHub(discardLogger)
+	a := newClient(&fakeConn{}, 1, []string{"snippet:1"}, discardLogger)
+	b := newClient(&fakeConn{}, 2, []string{"snippet:2"}, discardLogger)
+
+	h.handle(a, []byte(`{"type":"subscribe","channel":"snippet:1"}`))
+	h.handle(b, []byte(`{"type":"subscribe","channel":"snippet:2"}`))
+	assert.Equal(t, h.subscribers("snippet:1"), 1)
+
+	got := queued(a)
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0], `{"type":"subscribed","channel":"snippet:1"}`)
+	queued(b)
+
+	assert.Equal(t, h.publish("snippet:1", []byte(`{"event":"updated"}`)), 1)
+
+	got = queued(a)
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0], `{"type":"message","channel":"snippet:1","data":{"event":"updated"}}`)
+	assert.Equal(t, len(queued(b)), 0)
+
+	h.handle(a, []byte(`{"type":"unsubscribe","channel":"snippet:1"}`))
+	assert.Equal(t, h.subscribers("snippet:1"), 0)
+	assert.Equal(t, h.publish("snippet:1", []byte(`{}`)), 0)
+}
+
+func TestHubHandleErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "Not JSON",
+			message: `hello`,
+			want:    `{"type":"error","error":"message must be a JSON object"}`,
+		},
+		{
+			name:    "Invalid channel",
+			message: `{"type":"subscribe","channel":"Snippet 1"}`,
+			want:    `{"type":"error","channel":"Snippet 1","error":"invalid channel name"}`,
+		},
+		{
+			name:    "Another user's channel",
+			message: `{"type":"subscribe","channel":"user.2.stars"}`,
+			want:    `{"type":"error","channel":"user.2.stars","error":"not allowed to subscribe to channel"}`,
+		},
+		{
+			name:    "Channel not granted",
+			message: `{"type":"subscribe","channel":"snippet:2"}`,
+			want:    `{"type":"error","channel":"snippet:2","error":"not allowed to subscribe to channel"}`,
+		},
+		{
+			name:    "Publish",
+			message: `{"type":"publish","channel":"snippet:1","data":{}}`,
+			want:    `{"type":"error","channel":"snippet:1","error":"unknown message type"}`,
+		},
+		{
+			name:    "Unknown type",
+			message: `{"type":"shout","channel":"snippet:1"}`,
+			want:    `{"type":"error","channel":"snippet:1","error":"unknown message type"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHub(discardLogger)
+			c := newClient(&fakeConn{}, 1, []string{"snippet:1"}, discardLogger)
+
+			h.handle(c, []byte(tt.message))
+
+			got := queued(c)
+			assert.Equal(t, len(got), 1)
+			assert.Equal(t, got[0], tt.want)
+		})
+	}
+}
+
+func TestClientCanSubscribe(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(&fakeConn{}, 7, []string{"snippet:42", "user.8.stars"}, discardLogger)
+
+	tests := []struct {
+		channel string
+		want    bool
+	}{
+		{channel: "snippet:42", want: true},
+		{channel: "snippet:43", want: false},
+		{channel: "users", want: false},
+		{channel: "user.7", want: true},
+		{channel: "user:7.stars", want: true},
+		{channel: "user.70", want: false},
+		{channel: "user.8.stars", want: true},
+		{channel: "user.8.comments", want: false},
+		{channel: "user", want: false},
+		{channel: "user-me", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			assert.Equal(t, c.canSubscribe(tt.channel), tt.want)
+		})
+	}
+}
+
+func TestClientQueueFull(t *testing.T) {
+	t.Parallel()
+
+	h := newHub(discardLogger)
+	conn := &fakeConn{}
+	slow := newClient(conn, 1, nil, discardLogger)
+	h.subscribe(slow, "news")
+
+	for range sendQueueSize {
+		assert.Equal(t, h.publish("news", []byte(`1`)), 1)
+	}
+
+	assert.Equal(t, h.publish("news", []byte(`1`)), 0)
+	assert.Equal(t, conn.closed, true)
+}
+
+func TestClientWriteLoopBrokenConn(t *testing.T) {
+	t.Parallel()
+
+	conn := &fakeConn{broken: true}
+	c := newClient(conn, 1, nil, discardLogger)
+	c.queueMessage(message{Type: typeSubscribed, Channel: "news"})
+
+	done := make(chan struct{})
+	go func() {
+		c.writeLoop(time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write loop didn't stop after a failed write")
+	}
+
+	assert.Equal(t, conn.closed, true)
+	assert.Equal(t, c.queue([]byte(`1`)), false)
+}
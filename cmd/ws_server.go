@@ -2,84 +2,361 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"thabomoyo.co.uk/internal/live"
+	"thabomoyo.co.uk/internal/ticket"
 	"time"
 
 	"github.com/lesismal/nbio/nbhttp"
 	"github.com/lesismal/nbio/nbhttp/websocket"
 )
 
-func newUpgrader() *websocket.Upgrader {
-	u := websocket.NewUpgrader()
+// parseOrigins parses a comma-separated list of origins like
+// "https://example.com,http://localhost:8888" into a set.
+func parseOrigins(list string) (map[string]bool, error) {
+	origins := map[string]bool{}
+
+	for _, o := range strings.Split(list, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
 
-	u.CheckOrigin = func(request *http.Request) bool {
-		host, _, err := net.SplitHostPort(request.RemoteAddr)
-		if err != nil {
-			return false
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid origin %q", o)
 		}
-		fmt.Println("CheckOrigin:", host)
-		return host == "127.0.0.1" || host == "::1"
+
+		origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
 	}
 
-	u.OnOpen(func(c *websocket.Conn) {
-		fmt.Println("OnOpen:", c.RemoteAddr().String())
-	})
-	u.OnMessage(func(c *websocket.Conn, messageType websocket.MessageType, data []byte) {
-		fmt.Println("OnMessage:", messageType, string(data))
-		err := c.WriteMessage(messageType, data)
-		if err != nil {
-			return
+	return origins, nil
+}
+
+// checkOrigin returns an Upgrader.CheckOrigin that only lets pages from the
+// given origins connect. Requests without an Origin header don't come from a
+// browser, and are let through to the ticket check.
+func checkOrigin(origins map[string]bool) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
 		}
-	})
-	u.OnClose(func(c *websocket.Conn, err error) {
-		fmt.Println("OnClose:", c.RemoteAddr().String(), err)
-	})
 
-	return u
+		return origins[strings.ToLower(origin)]
+	}
+}
+
+// wsServer accepts WebSocket connections and hands them to the hub. It owns
+// the one upgrader every connection goes through, and keeps a registry of the
+// open connections so they can be counted and closed cleanly on shutdown.
+type wsServer struct {
+	logger        *slog.Logger
+	hub           *hub
+	secret        []byte
+	publishSecret []byte
+	upgrader      *websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closing bool
+
+	// drained is closed once the last connection has gone after shutdown has
+	// started.
+	drained chan struct{}
+}
+
+// newWSServer returns a server checking tickets signed with secret, and
+// taking publish requests carrying publishSecret. The two must differ: the
+// publish secret is sent over the wire, and anyone holding the ticket secret
+// can sign tickets for any user.
+func newWSServer(origins map[string]bool, secret, publishSecret []byte, logger *slog.Logger) *wsServer {
+	s := &wsServer{
+		logger:        logger,
+		hub:           newHub(logger),
+		secret:        secret,
+		publishSecret: publishSecret,
+		clients:       map[*client]struct{}{},
+		drained:       make(chan struct{}),
+	}
+
+	u := websocket.NewUpgrader()
+	u.KeepaliveTime = live.IdleTimeout
+	u.MessageLengthLimit = maxMessageSize
+	u.CheckOrigin = checkOrigin(origins)
+	u.OnMessage(s.onMessage)
+	u.OnClose(s.onClose)
+	s.upgrader = u
+
+	return s
 }
 
-func onWebsocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := newUpgrader().Upgrade(w, r, nil)
+func (s *wsServer) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /ws", s.handleWebsocket)
+	mux.HandleFunc("POST /publish", s.handlePublish)
+
+	return mux
+}
+
+// handleWebsocket upgrades requests carrying a valid ticket from the web app,
+// passed as the ticket query parameter since browsers can't set headers on a
+// WebSocket request.
+func (s *wsServer) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	if s.isClosing() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	claims, err := ticket.Verify(s.secret, r.URL.Query().Get("ticket"), time.Now())
+	if err != nil {
+		s.logger.Info("rejected connection", slog.String("remote", r.RemoteAddr), slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
 
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		fmt.Println("Failed:", err)
+		s.logger.Warn("upgrade failed", slog.String("remote", r.RemoteAddr), slog.Int("user", claims.UserID), slog.Any("err", err))
+		return
+	}
+
+	s.open(conn, claims)
+}
+
+// publishRequest is the body of a request to the publish endpoint.
+type publishRequest struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type publishResponse struct {
+	Subscribers int `json:"subscribers"`
+}
+
+// handlePublish passes data on to everyone subscribed to a channel. Clients
+// can't publish over their connections, so this is the only way in, and it's
+// only open to the web app: the request must carry the publish secret as a
+// bearer token.
+func (s *wsServer) handlePublish(w http.ResponseWriter, r *http.Request) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if len(s.publishSecret) == 0 || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), s.publishSecret) != 1 {
+		s.logger.Info("rejected publish", slog.String("remote", r.RemoteAddr))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMessageSize)
+
+	var req publishRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&req)
+	if err != nil || !validChannel(req.Channel) || len(req.Data) == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	n := s.hub.publish(req.Channel, req.Data)
+	s.logger.Debug("published", slog.String("channel", req.Channel), slog.Int("subscribers", n))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(publishResponse{Subscribers: n})
+}
+
+// open registers a newly upgraded connection as a client of the ticket's user.
+// The engine runs a connection's handler and its message and close callbacks
+// one after another, so nothing reaches onMessage or onClose before the
+// session is set here.
+func (s *wsServer) open(c *websocket.Conn, claims ticket.Claims) {
+	remote := c.RemoteAddr().String()
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		c.WriteMessage(websocket.CloseMessage, closePayload(closeGoingAway, "server shutting down"))
+		c.Close()
+		return
+	}
+	cl := newClient(c, claims.UserID, claims.Channels, s.logger.With(slog.String("remote", remote), slog.Int("user", claims.UserID)))
+	s.clients[cl] = struct{}{}
+	n := len(s.clients)
+	s.mu.Unlock()
+
+	c.SetSession(cl)
+	go cl.writeLoop(live.PingInterval)
+
+	cl.logger.Info("connection opened", slog.Int("connections", n))
+}
+
+func (s *wsServer) onMessage(c *websocket.Conn, messageType websocket.MessageType, data []byte) {
+	cl, ok := c.Session().(*client)
+	if !ok {
+		return
+	}
+
+	if messageType != websocket.TextMessage {
+		cl.queueMessage(message{Type: typeError, Error: "messages must be text"})
+		return
+	}
+
+	s.hub.handle(cl, data)
+}
+
+func (s *wsServer) onClose(c *websocket.Conn, err error) {
+	cl, ok := c.Session().(*client)
+	if !ok {
+		return
+	}
+
+	s.hub.remove(cl)
+	cl.close()
+
+	s.mu.Lock()
+	delete(s.clients, cl)
+	n := len(s.clients)
+	if s.closing && n == 0 {
+		close(s.drained)
+	}
+	s.mu.Unlock()
+
+	cl.logger.Info("connection closed", slog.Int("connections", n), slog.Any("err", err))
+}
+
+// connections returns the number of open connections.
+func (s *wsServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.clients)
+}
+
+func (s *wsServer) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closing
+}
+
+// shutdown stops accepting connections and sends every open one a close
+// frame, then waits for the clients to finish closing. Any still open when
+// ctx is done are closed without waiting.
+func (s *wsServer) shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return errors.New("already shutting down")
+	}
+	s.closing = true
+	clients := make([]*client, 0, len(s.clients))
+	for cl := range s.clients {
+		clients = append(clients, cl)
+	}
+	if len(clients) == 0 {
+		close(s.drained)
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("closing connections", slog.Int("connections", len(clients)))
+
+	for _, cl := range clients {
+		cl.goAway(closeGoingAway, "server shutting down")
+	}
+
+	select {
+	case <-s.drained:
+		return nil
+	case <-ctx.Done():
+		for _, cl := range clients {
+			cl.close()
+		}
+		return ctx.Err()
 	}
-	fmt.Println("Upgraded:", conn.RemoteAddr().String())
 }
 
 func main() {
-	mux := &http.ServeMux{}
+	addr := flag.String("addr", "localhost:8889", "Address to listen on")
+	allowedOrigins := flag.String("origins", "https://localhost:8888", "Comma-separated origins allowed to connect")
+	ticketSecret := flag.String("ticket-secret", "", "Secret shared with the web app for checking tickets")
+	publishSecret := flag.String("publish-secret", "", "Secret shared with the web app for publishing, different from -ticket-secret")
+	debug := flag.Bool("debug", false, "Enable debug logging")
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+
+	origins, err := parseOrigins(*allowedOrigins)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
-	mux.HandleFunc("GET /ws", onWebsocket)
+	if *ticketSecret == "" {
+		logger.Error("a -ticket-secret shared with the web app is required")
+		os.Exit(1)
+	}
+
+	if *publishSecret == "" || *publishSecret == *ticketSecret {
+		logger.Error("a -publish-secret different from -ticket-secret is required")
+		os.Exit(1)
+	}
+
+	srv := newWSServer(origins, []byte(*ticketSecret), []byte(*publishSecret), logger)
 
 	engine := nbhttp.NewEngine(nbhttp.Config{
 		Network:                 "tcp",
-		Addrs:                   []string{"localhost:8888"},
+		Addrs:                   []string{*addr},
 		MaxLoad:                 1000000,
 		ReleaseWebsocketPayload: true,
-		Handler:                 mux,
+		Handler:                 srv.routes(),
 	})
 
-	err := engine.Start()
+	err = engine.Start()
 	if err != nil {
-		fmt.Printf("nbio.Start failed: %v\n", err)
-
-		return
+		logger.Error("starting engine failed", slog.Any("err", err))
+		os.Exit(1)
 	}
+	logger.Info("listening", slog.String("addr", *addr))
 
 	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	<-interrupt
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	sig := <-interrupt
+	logger.Info("shutting down", slog.String("signal", sig.String()))
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
 
-	engineErr := engine.Shutdown(ctx)
-	if engineErr != nil {
-		panic(engineErr)
+	err = srv.shutdown(ctx)
+	if err != nil {
+		logger.Warn("not every connection closed cleanly", slog.Any("err", err))
+	}
+
+	// The engine gets its own time to stop, however long the connections
+	// took to close.
+	engineCtx, engineCancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer engineCancel()
+
+	err = engine.Shutdown(engineCtx)
+	if err != nil {
+		logger.Error("shutdown failed", slog.Any("err", err))
+		os.Exit(1)
 	}
 }
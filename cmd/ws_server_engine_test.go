@@ -0,0 +1,236 @@
+//go:build !race
+
+// These tests run a real nbhttp engine, whose poller shares memory between
+// goroutines in ways the race detector can't follow, so they're left out of
+// -race runs.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"thabomoyo.co.uk/internal/ticket"
+	"time"
+
+	"github.com/lesismal/nbio/nbhttp"
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+// testClient is a WebSocket connection to a test server, made with nbio's
+// own client.
+type testClient struct {
+	conn     *websocket.Conn
+	messages chan message
+	closed   chan error
+}
+
+func (tc *testClient) send(t *testing.T, m string) {
+	err := tc.conn.WriteMessage(websocket.TextMessage, []byte(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// next returns the next message the client receives.
+func (tc *testClient) next(t *testing.T) message {
+	select {
+	case m := <-tc.messages:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+// closeError waits for the connection to close and returns why.
+func (tc *testClient) closeError(t *testing.T) error {
+	select {
+	case err := <-tc.closed:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the connection to close")
+		return nil
+	}
+}
+
+type testWSServer struct {
+	*wsServer
+	addr   string
+	secret []byte
+	engine *nbhttp.Engine
+}
+
+// newTestWSServer starts a server on a free local port, in an nbhttp engine
+// like main's. The engine also makes the client connections.
+func newTestWSServer(t *testing.T) *testWSServer {
+	secret := []byte("secret")
+	srv := newWSServer(map[string]bool{testOrigin: true}, secret, []byte(testPublishSecret), discardLogger)
+
+	var addr string
+	engine := nbhttp.NewEngine(nbhttp.Config{
+		Network: "tcp",
+		Addrs:   []string{"127.0.0.1:0"},
+		Handler: srv.routes(),
+		Listen: func(network, address string) (net.Listener, error) {
+			ln, err := net.Listen(network, address)
+			if err == nil {
+				addr = ln.Addr().String()
+			}
+			return ln, err
+		},
+	})
+
+	err := engine.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(engine.Stop)
+
+	return &testWSServer{
+		wsServer: srv,
+		addr:     addr,
+		secret:   secret,
+		engine:   engine,
+	}
+}
+
+// connect dials the server as user 1 on an allowed page, with a ticket
+// granting the given channels.
+func (ts *testWSServer) connect(t *testing.T, channels ...string) *testClient {
+	tc := &testClient{
+		messages: make(chan message, 16),
+		closed:   make(chan error, 1),
+	}
+
+	u := websocket.NewUpgrader()
+	u.OnMessage(func(c *websocket.Conn, messageType websocket.MessageType, data []byte) {
+		var m message
+		if json.Unmarshal(data, &m) == nil {
+			tc.messages <- m
+		}
+	})
+	u.OnClose(func(c *websocket.Conn, err error) {
+		tc.closed <- err
+	})
+
+	tk, _ := ticket.Issue(ts.secret, ticket.Claims{UserID: 1, Channels: channels}, time.Now())
+	header := http.Header{"Origin": {testOrigin}}
+	d := &websocket.Dialer{Engine: ts.engine, Upgrader: u, DialTimeout: 2 * time.Second}
+
+	conn, _, err := d.Dial("ws://"+ts.addr+"/ws?ticket="+url.QueryEscape(tk), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.conn = conn
+	t.Cleanup(func() { conn.Close() })
+
+	return tc
+}
+
+// publish publishes data to a channel through the publish endpoint, as the
+// web app does.
+func (ts *testWSServer) publish(t *testing.T, channel, data string) {
+	body := strings.NewReader(`{"channel":"` + channel + `","data":` + data + `}`)
+
+	r, err := http.NewRequest(http.MethodPost, "http://"+ts.addr+"/publish", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+testPublishSecret)
+
+	rs, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+}
+
+// waitFor polls until cond is true.
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWSServerPubSub(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestWSServer(t)
+
+	subscriber := ts.connect(t, "snippet:1")
+	other := ts.connect(t)
+	waitFor(t, func() bool { return ts.connections() == 2 })
+
+	subscriber.send(t, `{"type":"subscribe","channel":"snippet:1"}`)
+	m := subscriber.next(t)
+	assert.Equal(t, m.Type, typeSubscribed)
+	assert.Equal(t, m.Channel, "snippet:1")
+
+	// The ticket was for user 1.
+	subscriber.send(t, `{"type":"subscribe","channel":"user.1.stars"}`)
+	m = subscriber.next(t)
+	assert.Equal(t, m.Type, typeSubscribed)
+
+	subscriber.send(t, `{"type":"subscribe","channel":"user.2.stars"}`)
+	m = subscriber.next(t)
+	assert.Equal(t, m.Type, typeError)
+
+	// Only the subscriber's ticket grants the snippet's channel.
+	other.send(t, `{"type":"subscribe","channel":"snippet:1"}`)
+	m = other.next(t)
+	assert.Equal(t, m.Type, typeError)
+
+	other.send(t, `{"type":"publish","channel":"snippet:1","data":{"event":"forged"}}`)
+	m = other.next(t)
+	assert.Equal(t, m.Type, typeError)
+
+	ts.publish(t, "snippet:1", `{"event":"updated"}`)
+	m = subscriber.next(t)
+	assert.Equal(t, m.Type, typeMessage)
+	assert.Equal(t, m.Channel, "snippet:1")
+	assert.Equal(t, string(m.Data), `{"event":"updated"}`)
+
+	other.conn.Close()
+	waitFor(t, func() bool { return ts.connections() == 1 })
+}
+
+func TestWSServerShutdown(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestWSServer(t)
+
+	clients := []*testClient{ts.connect(t), ts.connect(t)}
+	waitFor(t, func() bool { return ts.connections() == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := ts.shutdown(ctx)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ts.connections(), 0)
+
+	for _, tc := range clients {
+		var closeErr *websocket.CloseError
+		assert.Equal(t, errors.As(tc.closeError(t), &closeErr), true)
+		assert.Equal(t, closeErr.Code, closeGoingAway)
+	}
+
+	tk, _ := ticket.Issue(ts.secret, ticket.Claims{UserID: 1}, time.Now())
+	rr := httptest.NewRecorder()
+	ts.routes().ServeHTTP(rr, upgradeRequest(tk, testOrigin))
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+}
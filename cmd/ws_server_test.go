@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"thabomoyo.co.uk/internal/ticket"
+	"time"
+)
+
+func TestParseOrigins(t *testing.T) {
+	t.Parallel()
+
+	origins, err := parseOrigins("https://Example.com, http://localhost:8888/,")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(origins), 2)
+	assert.Equal(t, origins["https://example.com"], true)
+	assert.Equal(t, origins["http://localhost:8888"], true)
+
+	for _, list := range []string{"example.com", "ftp://example.com", "https://example.com/path"} {
+		_, err := parseOrigins(list)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	t.Parallel()
+
+	check := checkOrigin(map[string]bool{"https://example.com": true})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{
+			name:   "Allowed",
+			origin: "https://example.com",
+			want:   true,
+		},
+		{
+			name:   "Allowed, different case",
+			origin: "https://EXAMPLE.com",
+			want:   true,
+		},
+		{
+			name:   "Other site",
+			origin: "https://evil.example",
+			want:   false,
+		},
+		{
+			name:   "Other scheme",
+			origin: "http://example.com",
+			want:   false,
+		},
+		{
+			name:   "Null",
+			origin: "null",
+			want:   false,
+		},
+		{
+			name:   "No Origin header",
+			origin: "",
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			assert.Equal(t, check(r), tt.want)
+		})
+	}
+}
+
+const (
+	testOrigin        = "https://snippets.example"
+	testPublishSecret = "publish-secret"
+)
+
+// upgradeRequest returns a WebSocket handshake request for the server.
+func upgradeRequest(tk, origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/ws?ticket="+url.QueryEscape(tk), nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+
+	return r
+}
+
+func TestWSServerRejects(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	srv := newWSServer(map[string]bool{testOrigin: true}, secret, []byte(testPublishSecret), discardLogger)
+
+	valid, _ := ticket.Issue(secret, ticket.Claims{UserID: 1}, time.Now())
+	expired, _ := ticket.Issue(secret, ticket.Claims{UserID: 1}, time.Now().Add(-2*ticket.TTL))
+	forged, _ := ticket.Issue([]byte("other"), ticket.Claims{UserID: 1}, time.Now())
+
+	tests := []struct {
+		name       string
+		ticket     string
+		origin     string
+		wantStatus int
+	}{
+		{
+			name:       "No ticket",
+			origin:     testOrigin,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Expired ticket",
+			ticket:     expired,
+			origin:     testOrigin,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Forged ticket",
+			ticket:     forged,
+			origin:     testOrigin,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Other origin",
+			ticket:     valid,
+			origin:     "https://evil.example",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			srv.routes().ServeHTTP(rr, upgradeRequest(tt.ticket, tt.origin))
+
+			assert.Equal(t, rr.Code, tt.wantStatus)
+		})
+	}
+
+	assert.Equal(t, srv.connections(), 0)
+}
+
+func TestWSServerPublish(t *testing.T) {
+	t.Parallel()
+
+	srv := newWSServer(map[string]bool{testOrigin: true}, []byte("secret"), []byte(testPublishSecret), discardLogger)
+
+	c := newClient(&fakeConn{}, 1, nil, discardLogger)
+	srv.hub.subscribe(c, "snippet:1")
+
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		wantStatus    int
+		wantBody      string
+	}{
+		{
+			name:          "Published",
+			authorization: "Bearer " + testPublishSecret,
+			body:          `{"channel":"snippet:1","data":{"event":"updated"}}`,
+			wantStatus:    http.StatusOK,
+			wantBody:      `{"subscribers":1}`,
+		},
+		{
+			name:       "No secret",
+			body:       `{"channel":"snippet:1","data":{}}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "Ticket secret",
+			authorization: "Bearer secret",
+			body:          `{"channel":"snippet:1","data":{}}`,
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Wrong secret",
+			authorization: "Bearer guess",
+			body:          `{"channel":"snippet:1","data":{}}`,
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Invalid channel",
+			authorization: "Bearer " + testPublishSecret,
+			body:          `{"channel":"Snippet 1","data":{}}`,
+			wantStatus:    http.StatusBadRequest,
+		},
+		{
+			name:          "No data",
+			authorization: "Bearer " + testPublishSecret,
+			body:          `{"channel":"snippet:1"}`,
+			wantStatus:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			srv.routes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantStatus)
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.TrimSpace(rr.Body.String()), tt.wantBody)
+			}
+		})
+	}
+
+	got := queued(c)
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0], `{"type":"message","channel":"snippet:1","data":{"event":"updated"}}`)
+}
@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Op is what happened to a line going from the old text to the new.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns the name of the op, for use as a CSS class.
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// Prefix returns the marker unified diffs put in front of a line.
+func (op Op) Prefix() string {
+	switch op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// Line is one line of a diff. OldNum and NewNum are 1-based line numbers in
+// the old and new text, and are 0 where the line doesn't appear on that side.
+type Line struct {
+	Op     Op
+	Text   string
+	OldNum int
+	NewNum int
+}
+
+// Lines diffs a and b line by line using their longest common subsequence.
+// Snippets are small, so the quadratic table is fine.
+func Lines(a, b string) []Line {
+	old, new := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the LCS of old[i:] and new[j:].
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []Line
+	i, j := 0, 0
+
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			lines = append(lines, Line{Op: Equal, Text: old[i], OldNum: i + 1, NewNum: j + 1})
+			i++
+			j++
+		case j == len(new) || (i < len(old) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Line{Op: Delete, Text: old[i], OldNum: i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: new[j], NewNum: j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Hunk is a run of changed lines plus the unchanged lines around them, as
+// shown in a unified diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Hunks groups the lines of a diff into hunks, keeping up to context
+// unchanged lines either side of each change. Changes close enough to share
+// context are merged into one hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+
+	start := -1
+	end := 0
+
+	flush := func() {
+		if start == -1 {
+			return
+		}
+
+		h := Hunk{Lines: lines[start:end]}
+		for _, l := range h.Lines {
+			if l.OldNum != 0 {
+				if h.OldStart == 0 {
+					h.OldStart = l.OldNum
+				}
+				h.OldLines++
+			}
+			if l.NewNum != 0 {
+				if h.NewStart == 0 {
+					h.NewStart = l.NewNum
+				}
+				h.NewLines++
+			}
+		}
+
+		hunks = append(hunks, h)
+		start = -1
+	}
+
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+
+		from := max(0, i-context)
+		if start != -1 && from > end {
+			flush()
+		}
+		if start == -1 {
+			start = from
+		}
+		end = min(len(lines), i+context+1)
+	}
+
+	flush()
+
+	return hunks
+}
+
+// Row is one row of a side-by-side diff. Old or New is nil where that side has
+// no line.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+// SideBySide lays the lines of a diff out in two columns, pairing each run of
+// deletions with the insertions that follow it.
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Old: &lines[i], New: &lines[i]})
+			i++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Op == Delete; i++ {
+			deleted = append(deleted, &lines[i])
+		}
+		for ; i < len(lines) && lines[i].Op == Insert; i++ {
+			inserted = append(inserted, &lines[i])
+		}
+
+		for k := 0; k < max(len(deleted), len(inserted)); k++ {
+			var row Row
+			if k < len(deleted) {
+				row.Old = deleted[k]
+			}
+			if k < len(inserted) {
+				row.New = inserted[k]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
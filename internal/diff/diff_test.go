@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+)
+
+// render writes a diff in a compact unified style for comparison.
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.Op.Prefix() + l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: " one\n-two\n+2\n three\n",
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one",
+			want: "+one\n",
+		},
+		{
+			name: "CRLF and trailing newline",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, render(Lines(tt.a, tt.b)), tt.want)
+		})
+	}
+}
+
+func TestHunks(t *testing.T) {
+	t.Parallel()
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"
+	b := "1\n2\n3\nfour\n5\n6\n7\n8\n9\nten"
+
+	hunks := Hunks(Lines(a, b), 1)
+
+	assert.Equal(t, len(hunks), 2)
+	assert.Equal(t, render(hunks[0].Lines), " 3\n-4\n+four\n 5\n")
+	assert.Equal(t, hunks[0].OldStart, 3)
+	assert.Equal(t, hunks[0].OldLines, 3)
+	assert.Equal(t, hunks[0].NewStart, 3)
+	assert.Equal(t, hunks[0].NewLines, 3)
+	assert.Equal(t, render(hunks[1].Lines), " 9\n-10\n+ten\n")
+
+	// With more context the two changes share a single hunk.
+	assert.Equal(t, len(Hunks(Lines(a, b), 3)), 1)
+}
+
+func TestSideBySide(t *testing.T) {
+	t.Parallel()
+
+	rows := SideBySide(Lines("one\ntwo\nthree", "one\n2\n3\nthree"))
+
+	assert.Equal(t, len(rows), 4)
+	assert.Equal(t, rows[1].Old.Text, "two")
+	assert.Equal(t, rows[1].New.Text, "2")
+	assert.Equal(t, rows[2].Old == nil, true)
+	assert.Equal(t, rows[2].New.Text, "3")
+}
@@ -0,0 +1,261 @@
+package highlight
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Plaintext is the language of snippets that aren't highlighted.
+const Plaintext = "plaintext"
+
+// Language is a language snippets can be tagged with. Key is stored in the
+// database and is also the name of the chroma lexer used to highlight it. Ext
+// is the file extension used when a snippet is downloaded.
+type Language struct {
+	Key  string
+	Name string
+	Ext  string
+}
+
+// Languages lists every language a snippet may be tagged with, in the order
+// they're offered on the create form.
+var Languages = []Language{
+	{Plaintext, "Plain text", "txt"},
+	{"bash", "Bash", "sh"},
+	{"c", "C", "c"},
+	{"cpp", "C++", "cpp"},
+	{"css", "CSS", "css"},
+	{"go", "Go", "go"},
+	{"html", "HTML", "html"},
+	{"java", "Java", "java"},
+	{"javascript", "JavaScript", "js"},
+	{"json", "JSON", "json"},
+	{"markdown", "Markdown", "md"},
+	{"php", "PHP", "php"},
+	{"python", "Python", "py"},
+	{"ruby", "Ruby", "rb"},
+	{"rust", "Rust", "rs"},
+	{"sql", "SQL", "sql"},
+	{"typescript", "TypeScript", "ts"},
+	{"yaml", "YAML", "yaml"},
+}
+
+// Keys returns the Key of every entry in Languages.
+func Keys() []string {
+	keys := make([]string, len(Languages))
+	for i, l := range Languages {
+		keys[i] = l.Key
+	}
+
+	return keys
+}
+
+// Lookup returns the entry in Languages for key, falling back to Plaintext.
+func Lookup(key string) Language {
+	for _, l := range Languages {
+		if l.Key == key {
+			return l
+		}
+	}
+
+	return Languages[0]
+}
+
+// ForFilename returns the key of the language whose file extension name has,
+// or "" if none does.
+func ForFilename(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if ext == "" {
+		return ""
+	}
+
+	for _, l := range Languages {
+		if l.Ext == ext {
+			return l.Key
+		}
+	}
+
+	return ""
+}
+
+// Name returns the display name of a language key.
+func Name(key string) string {
+	return Lookup(key).Name
+}
+
+// signals are patterns that suggest content is written in a language. Chroma's
+// own analysers only cover a handful of languages, and misidentify some of
+// those, so Detect scores content against these instead.
+var signals = map[string][]*regexp.Regexp{
+	"bash": {
+		regexp.MustCompile(`\A#!.*\b(ba|z)?sh\b`),
+		regexp.MustCompile(`(?m)^\s*(echo|export|source|cd) `),
+		regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`),
+		regexp.MustCompile(`\$\{?\w+\}?`),
+	},
+	"c": {
+		regexp.MustCompile(`(?m)^#include\s*<\w+\.h>`),
+		regexp.MustCompile(`\bint\s+main\s*\(`),
+		regexp.MustCompile(`\b(printf|malloc|free)\s*\(`),
+	},
+	"cpp": {
+		regexp.MustCompile(`(?m)^#include\s*<\w+>`),
+		regexp.MustCompile(`\bstd::`),
+		regexp.MustCompile(`\b(cout|cin)\s*(<<|>>)`),
+		regexp.MustCompile(`\b(template\s*<|namespace\s+\w+)`),
+	},
+	"css": {
+		regexp.MustCompile(`(?m)^\s*[.#]?[\w-]+(\s*[,>]?\s*[.#]?[\w-]+)*\s*\{`),
+		regexp.MustCompile(`(?m)^\s*[\w-]+\s*:\s*[^;{}]+;\s*$`),
+		regexp.MustCompile(`@media\b`),
+	},
+	"go": {
+		regexp.MustCompile(`(?m)^package \w+`),
+		regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`),
+		regexp.MustCompile(`:=`),
+		regexp.MustCompile(`\b(fmt|errors|http)\.\w+`),
+	},
+	"html": {
+		regexp.MustCompile(`(?i)<!doctype html`),
+		regexp.MustCompile(`(?i)<(html|head|body|div|span|p|a|ul|li)[\s>]`),
+		regexp.MustCompile(`(?i)</(html|head|body|div|span|p|a|ul|li)>`),
+	},
+	"java": {
+		regexp.MustCompile(`\bpublic\s+(static\s+)?(class|void|final)\b`),
+		regexp.MustCompile(`\bSystem\.out\.print`),
+		regexp.MustCompile(`(?m)^import\s+java\.`),
+	},
+	"javascript": {
+		regexp.MustCompile(`\b(const|let|var)\s+\w+\s*=`),
+		regexp.MustCompile(`\bfunction\s*\w*\s*\(`),
+		regexp.MustCompile(`=>`),
+		regexp.MustCompile(`\b(console\.log|document\.|require\()`),
+	},
+	"php": {
+		regexp.MustCompile(`<\?php`),
+		regexp.MustCompile(`\$\w+\s*=`),
+		regexp.MustCompile(`\b(echo|function)\s+\$?\w+`),
+	},
+	"python": {
+		regexp.MustCompile(`\A#!.*\bpython`),
+		regexp.MustCompile(`(?m)^\s*def \w+\(.*\):`),
+		regexp.MustCompile(`(?m)^(import \w+|from [\w.]+ import)`),
+		regexp.MustCompile(`\b(self\.|print\(|elif\b|None\b)`),
+	},
+	"ruby": {
+		regexp.MustCompile(`(?m)^\s*def \w+[^:]*$`),
+		regexp.MustCompile(`(?m)^\s*end\s*$`),
+		regexp.MustCompile(`\b(puts|attr_accessor|require)\s`),
+	},
+	"rust": {
+		regexp.MustCompile(`\bfn\s+\w+\s*[(<]`),
+		regexp.MustCompile(`\blet\s+mut\b`),
+		regexp.MustCompile(`\b(println|vec|format)!`),
+		regexp.MustCompile(`\b(impl|pub fn|use std::)`),
+	},
+	"sql": {
+		regexp.MustCompile(`(?im)^\s*(SELECT|INSERT INTO|UPDATE|DELETE FROM|CREATE (TABLE|INDEX)|ALTER TABLE)\b`),
+		regexp.MustCompile(`(?i)\b(FROM|WHERE|JOIN|GROUP BY|ORDER BY)\b`),
+	},
+	"typescript": {
+		regexp.MustCompile(`:\s*(string|number|boolean|any|void)\b`),
+		regexp.MustCompile(`\b(interface|type)\s+\w+\s*(=|\{)`),
+		regexp.MustCompile(`\b(const|let)\s+\w+\s*:\s*\w+`),
+	},
+	"yaml": {
+		regexp.MustCompile(`(?m)^---\s*$`),
+		regexp.MustCompile(`(?m)^[\w-]+:(\s|$)`),
+		regexp.MustCompile(`(?m)^\s+- \w+`),
+	},
+}
+
+// Detect guesses the language content is written in, returning Plaintext if
+// nothing stands out.
+func Detect(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	best, bestScore := Plaintext, 0
+
+	// Walk Languages rather than the map so ties are broken the same way
+	// every time.
+	for _, l := range Languages {
+		score := 0
+		for _, rx := range signals[l.Key] {
+			if rx.MatchString(content) {
+				score++
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = l.Key, score
+		}
+	}
+
+	// A single weak signal isn't enough to go on.
+	if bestScore < 2 {
+		return Plaintext
+	}
+
+	return best
+}
+
+var (
+	formatter = html.New(html.WithClasses(true))
+	style     = styles.Get("github")
+)
+
+// HTML returns content highlighted as language, as a pre element with a span
+// per line and spans carrying chroma CSS classes. Nothing in the output uses
+// inline styles, so it's safe under the site's Content-Security-Policy given
+// the rules in highlight.css.
+func HTML(content, language string) (template.HTML, error) {
+	return format(formatter, content, language)
+}
+
+// HTMLWithLines is like HTML, but numbers the lines and wraps the result in
+// its own pre element. Each line number links to itself, with an ID made of
+// prefix and the number, so lines can be linked to.
+func HTMLWithLines(content, language, prefix string) (template.HTML, error) {
+	f := html.New(html.WithClasses(true), html.WithLineNumbers(true), html.WithLinkableLineNumbers(true, prefix))
+
+	return format(f, content, language)
+}
+
+func format(f *html.Formatter, content, language string) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Get(Plaintext)
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	err = f.Format(&b, style, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(b.String()), nil
+}
+
+// WriteCSS writes the stylesheet for the classes used by HTML. It's the source
+// of ui/static/css/highlight.css.
+func WriteCSS(w io.Writer) error {
+	return formatter.WriteCSS(w, style)
+}
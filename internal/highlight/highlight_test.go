@@ -0,0 +1,106 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Go",
+			content: "package main\n\nfunc main() {\n\tmsg := \"hi\"\n\tfmt.Println(msg)\n}",
+			want:    "go",
+		},
+		{
+			name:    "Python",
+			content: "import os\n\ndef main():\n    print(os.getcwd())",
+			want:    "python",
+		},
+		{
+			name:    "Bash",
+			content: "#!/bin/bash\necho \"hello $USER\"",
+			want:    "bash",
+		},
+		{
+			name:    "SQL",
+			content: "SELECT id, title FROM snippets WHERE id = 1;",
+			want:    "sql",
+		},
+		{
+			name:    "JSON",
+			content: `{"title": "An old silent pond", "expires": 7}`,
+			want:    "json",
+		},
+		{
+			name:    "Prose",
+			content: "An old silent pond... A frog jumps into the pond, splash! Silence again.",
+			want:    Plaintext,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.content), tt.want)
+		})
+	}
+}
+
+func TestForFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{name: "Known extension", filename: "main.go", want: "go"},
+		{name: "Upper case", filename: "README.TXT", want: Plaintext},
+		{name: "Path", filename: "scripts/deploy.sh", want: "bash"},
+		{name: "Markdown", filename: "notes.md", want: "markdown"},
+		{name: "Unknown extension", filename: "photo.png", want: ""},
+		{name: "No extension", filename: "Makefile", want: ""},
+		{name: "Empty", filename: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ForFilename(tt.filename), tt.want)
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	t.Parallel()
+
+	out, err := HTML("x := \"<b>\"", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Contains(string(out), "<b>"), false)
+	assert.Equal(t, strings.Contains(string(out), "&lt;b&gt;"), true)
+	assert.Equal(t, strings.Contains(string(out), "style="), false)
+	assert.Equal(t, strings.Contains(string(out), `class="`), true)
+}
+
+func TestHTMLWithLines(t *testing.T) {
+	t.Parallel()
+
+	out, err := HTMLWithLines("package main\n\nfunc main() {}\n", "go", "f1-L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Contains(string(out), `id="f1-L3"`), true)
+	assert.Equal(t, strings.Contains(string(out), `href="#f1-L3"`), true)
+	assert.Equal(t, strings.Contains(string(out), `id="f1-L4"`), false)
+	assert.Equal(t, strings.Contains(string(out), "style="), false)
+}
@@ -0,0 +1,200 @@
+// Package live pushes changes to snippets to the browsers viewing them, over
+// WebSockets.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+// Event types, telling viewers what changed.
+const (
+	EventUpdated   = "updated"
+	EventCommented = "commented"
+	EventDeleted   = "deleted"
+)
+
+// Event is sent as JSON to everyone viewing a snippet when it changes.
+type Event struct {
+	Type      string `json:"type"`
+	SnippetID int    `json:"snippet_id"`
+}
+
+// Watcher is a connection watching a snippet. *websocket.Conn is one.
+type Watcher interface {
+	WriteMessage(messageType websocket.MessageType, data []byte) error
+	Close() error
+}
+
+const (
+	// PingInterval is how often connections are pinged. Browsers answer pings
+	// on their own, which keeps the connection alive through proxies.
+	PingInterval = 30 * time.Second
+
+	// IdleTimeout is how long a connection can go without hearing from the
+	// browser, pongs included, before it's dropped.
+	IdleTimeout = 2*PingInterval + 10*time.Second
+)
+
+// Hub keeps track of who is watching each snippet, and passes events on to
+// them. Each snippet is its own channel: publishing to one doesn't reach the
+// watchers of another.
+type Hub struct {
+	logger   *slog.Logger
+	upgrader *websocket.Upgrader
+
+	mu       sync.Mutex
+	watchers map[int]map[Watcher]struct{}
+}
+
+func NewHub(logger *slog.Logger) *Hub {
+	h := &Hub{
+		logger:   logger,
+		watchers: map[int]map[Watcher]struct{}{},
+	}
+
+	// The default origin check only lets pages on this site connect, so
+	// another site can't open a connection riding on a viewer's cookies.
+	u := websocket.NewUpgrader()
+	u.KeepaliveTime = IdleTimeout
+	u.OnClose(func(c *websocket.Conn, err error) {
+		if snippetID, ok := c.Session().(int); ok {
+			h.Unsubscribe(snippetID, c)
+		}
+	})
+	h.upgrader = u
+
+	return h
+}
+
+// Upgrade turns the request into a WebSocket connection watching the snippet
+// with the given ID. The caller must already have checked that the current
+// user can see it. If the upgrade fails an error response has been written.
+func (h *Hub) Upgrade(w http.ResponseWriter, r *http.Request, snippetID int) error {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	// The server's write timeout would otherwise still apply to the hijacked
+	// connection and cut it off.
+	err = conn.SetWriteDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	conn.SetSession(snippetID)
+	h.Subscribe(snippetID, conn)
+
+	return nil
+}
+
+func (h *Hub) Subscribe(snippetID int, w Watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watchers[snippetID] == nil {
+		h.watchers[snippetID] = map[Watcher]struct{}{}
+	}
+	h.watchers[snippetID][w] = struct{}{}
+}
+
+func (h *Hub) Unsubscribe(snippetID int, w Watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.watchers[snippetID], w)
+	if len(h.watchers[snippetID]) == 0 {
+		delete(h.watchers, snippetID)
+	}
+}
+
+// Watching returns the number of connections watching a snippet.
+func (h *Hub) Watching(snippetID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.watchers[snippetID])
+}
+
+// snapshot returns the watchers of a snippet, so they can be written to
+// without holding the lock.
+func (h *Hub) snapshot(snippetID int) []Watcher {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	watchers := make([]Watcher, 0, len(h.watchers[snippetID]))
+	for w := range h.watchers[snippetID] {
+		watchers = append(watchers, w)
+	}
+
+	return watchers
+}
+
+// Publish sends an event of the given type to everyone watching a snippet.
+// Watchers that can't be written to are dropped.
+func (h *Hub) Publish(snippetID int, eventType string) {
+	data, err := json.Marshal(Event{Type: eventType, SnippetID: snippetID})
+	if err != nil {
+		h.logger.Error("encoding live event failed", slog.Any("err", err))
+		return
+	}
+
+	h.send(snippetID, websocket.TextMessage, data)
+}
+
+func (h *Hub) send(snippetID int, messageType websocket.MessageType, data []byte) {
+	for _, w := range h.snapshot(snippetID) {
+		err := w.WriteMessage(messageType, data)
+		if err != nil {
+			h.Unsubscribe(snippetID, w)
+			w.Close()
+		}
+	}
+}
+
+// Run pings every watcher every PingInterval until ctx is cancelled, then
+// closes every connection.
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			snippetIDs := make([]int, 0, len(h.watchers))
+			for id := range h.watchers {
+				snippetIDs = append(snippetIDs, id)
+			}
+			h.mu.Unlock()
+
+			for _, id := range snippetIDs {
+				h.send(id, websocket.PingMessage, nil)
+			}
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	watchers := h.watchers
+	h.watchers = map[int]map[Watcher]struct{}{}
+	h.mu.Unlock()
+
+	for _, ws := range watchers {
+		for w := range ws {
+			w.Close()
+		}
+	}
+}
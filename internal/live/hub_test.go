@@ -0,0 +1,124 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"time"
+
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+// fakeWatcher records what's written to it, failing every write if broken.
+type fakeWatcher struct {
+	mu       sync.Mutex
+	messages []string
+	pings    int
+	closed   bool
+	broken   bool
+}
+
+func (f *fakeWatcher) WriteMessage(messageType websocket.MessageType, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.broken {
+		return errors.New("broken pipe")
+	}
+
+	if messageType == websocket.PingMessage {
+		f.pings++
+	} else {
+		f.messages = append(f.messages, string(data))
+	}
+
+	return nil
+}
+
+func (f *fakeWatcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+
+	return nil
+}
+
+func newTestHub() *Hub {
+	return NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestHubPublish(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHub()
+
+	a, b, other := &fakeWatcher{}, &fakeWatcher{}, &fakeWatcher{}
+	h.Subscribe(1, a)
+	h.Subscribe(1, b)
+	h.Subscribe(2, other)
+
+	h.Publish(1, EventUpdated)
+
+	want := `{"type":"updated","snippet_id":1}`
+	assert.Equal(t, len(a.messages), 1)
+	assert.Equal(t, a.messages[0], want)
+	assert.Equal(t, len(b.messages), 1)
+	assert.Equal(t, len(other.messages), 0)
+
+	h.Unsubscribe(1, a)
+	h.Publish(1, EventCommented)
+
+	assert.Equal(t, len(a.messages), 1)
+	assert.Equal(t, len(b.messages), 2)
+	assert.Equal(t, h.Watching(1), 1)
+}
+
+func TestHubDropsBrokenWatchers(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHub()
+
+	ok, broken := &fakeWatcher{}, &fakeWatcher{broken: true}
+	h.Subscribe(1, ok)
+	h.Subscribe(1, broken)
+
+	h.Publish(1, EventUpdated)
+
+	assert.Equal(t, h.Watching(1), 1)
+	assert.Equal(t, broken.closed, true)
+	assert.Equal(t, ok.closed, false)
+	assert.Equal(t, len(ok.messages), 1)
+}
+
+func TestHubRunClosesOnCancel(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHub()
+
+	w := &fakeWatcher{}
+	h.Subscribe(1, w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		h.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hub didn't stop after its context was cancelled")
+	}
+
+	assert.Equal(t, w.closed, true)
+	assert.Equal(t, h.Watching(1), 0)
+}
@@ -0,0 +1,90 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Publisher passes events on to the notification server's publish endpoint,
+// so that clients subscribed there to a snippet's channel hear about it too.
+// A nil *Publisher publishes nothing.
+type Publisher struct {
+	url    string
+	secret []byte
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewPublisher returns a Publisher posting to url, which is the notification
+// server's publish endpoint, e.g. "http://localhost:8889/publish". secret is
+// the publish secret shared with that server.
+func NewPublisher(url string, secret []byte, logger *slog.Logger) *Publisher {
+	return &Publisher{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+	}
+}
+
+// publishRequest is the body the publish endpoint expects.
+type publishRequest struct {
+	Channel string `json:"channel"`
+	Data    Event  `json:"data"`
+}
+
+// Channel returns the name of the notification server channel carrying a
+// snippet's events.
+func Channel(snippetID int) string {
+	return "snippet:" + strconv.Itoa(snippetID)
+}
+
+// Publish sends an event of the given type about a snippet in the background,
+// so a slow or unreachable notification server doesn't hold up the request
+// that caused it. Failures are logged.
+func (p *Publisher) Publish(snippetID int, eventType string) {
+	if p == nil {
+		return
+	}
+
+	go func() {
+		err := p.send(snippetID, eventType)
+		if err != nil {
+			p.logger.Warn("publishing to the notification server failed", slog.Int("snippet", snippetID), slog.Any("err", err))
+		}
+	}()
+}
+
+func (p *Publisher) send(snippetID int, eventType string) error {
+	body, err := json.Marshal(publishRequest{
+		Channel: Channel(snippetID),
+		Data:    Event{Type: eventType, SnippetID: snippetID},
+	})
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+string(p.secret))
+
+	rs, err := p.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", rs.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package live
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+)
+
+func TestPublisherSend(t *testing.T) {
+	t.Parallel()
+
+	var authorization, body string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+
+		if authorization != "Bearer publish-secret" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"subscribers":1}`))
+	}))
+	defer ts.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewPublisher(ts.URL+"/publish", []byte("publish-secret"), logger)
+
+	err := p.send(42, EventUpdated)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, authorization, "Bearer publish-secret")
+	assert.Equal(t, body, `{"channel":"snippet:42","data":{"type":"updated","snippet_id":42}}`)
+
+	p = NewPublisher(ts.URL+"/publish", []byte("wrong"), logger)
+
+	err = p.send(42, EventUpdated)
+	assert.Equal(t, err != nil, true)
+
+	// A nil Publisher is how the web app runs without a notification server.
+	var none *Publisher
+	none.Publish(42, EventUpdated)
+}
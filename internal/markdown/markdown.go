@@ -0,0 +1,95 @@
+// Package markdown renders snippets written in Markdown to HTML that's safe
+// to put in a page.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"thabomoyo.co.uk/internal/highlight"
+)
+
+var (
+	converter = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100))),
+	)
+	policy = newPolicy()
+)
+
+// newPolicy allows what goldmark produces for GitHub Flavored Markdown, plus
+// the chroma classes in highlighted code blocks. Anything else, like script
+// tags, inline styles, event handlers and javascript: links, is stripped.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^chroma$`)).OnElements("pre")
+	// Token classes are at most three characters, but each line is wrapped
+	// in "line" and "cl" spans too.
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(?:[a-z][a-z0-9]{0,2}|line|cl)$`)).OnElements("span")
+
+	// Task list items.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return p
+}
+
+// HTML renders source as Markdown and sanitizes the result. Raw HTML in the
+// source isn't rendered. Fenced code blocks are highlighted in the language
+// given after the opening fence.
+func HTML(source string) (template.HTML, error) {
+	var b bytes.Buffer
+
+	err := converter.Convert([]byte(source), &b)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(policy.SanitizeBytes(b.Bytes())), nil
+}
+
+// codeBlockRenderer renders fenced code blocks with the same highlighting as
+// code snippets.
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := range lines.Len() {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	language := string(n.Language(source))
+	if language == "" {
+		language = highlight.Plaintext
+	}
+
+	html, err := highlight.HTML(code.String(), language)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	w.WriteString(string(html))
+	w.WriteString("\n")
+
+	return ast.WalkSkipChildren, nil
+}
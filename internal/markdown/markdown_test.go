@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "Basic formatting",
+			source: "# Notes\n\nSome *emphasis* and a [link](https://example.com).",
+			want:   []string{"<h1", "<em>emphasis</em>", `href="https://example.com"`},
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:   []string{"<table>", "<td>1</td>"},
+		},
+		{
+			name:    "Fenced code block",
+			source:  "```go\nfunc main() {}\n```",
+			want:    []string{`<pre class="chroma"><code>`, `<span class="line"><span class="cl">`, `<span class="kd">func</span>`},
+			notWant: []string{"style="},
+		},
+		{
+			name:    "Fenced code block is escaped",
+			source:  "```\n<script>alert(1)</script>\n```",
+			want:    []string{"&lt;script&gt;"},
+			notWant: []string{"<script>"},
+		},
+		{
+			name:    "Raw HTML",
+			source:  "<script>alert(1)</script>\n\n<img src=x onerror=alert(1)>",
+			notWant: []string{"<script", "onerror"},
+		},
+		{
+			name:    "JavaScript link",
+			source:  "[click](javascript:alert(1))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "Class on inline HTML",
+			source:  "Text <span class=\"notice\">here</span>",
+			notWant: []string{"notice"},
+		},
+		{
+			name:   "Task list",
+			source: "- [x] done\n- [ ] todo",
+			want:   []string{`type="checkbox"`, "checked"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := HTML(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tt.want {
+				assert.Equal(t, strings.Contains(string(out), s), true)
+			}
+			for _, s := range tt.notWant {
+				assert.Equal(t, strings.Contains(string(out), s), false)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Comment is a comment on a snippet. Comments can be anchored to a line of
+// one of the snippet's files, given by File (1-based, like File.Number) and
+// Line. Both are 0 for a comment on the snippet as a whole.
+type Comment struct {
+	ID          int
+	SnippetID   int
+	SnippetSlug string
+	UserID      int
+	UserName    string
+	File        int
+	Line        int
+	Body        string
+	Created     time.Time
+	Updated     time.Time
+}
+
+// Edited reports whether the comment has been changed since it was posted.
+func (c Comment) Edited() bool {
+	return c.Updated.After(c.Created)
+}
+
+// Thread is the comments on one line of a snippet, or on the snippet as a
+// whole if Line is 0, oldest first.
+type Thread struct {
+	File     int
+	Line     int
+	Comments []Comment
+}
+
+// Anchor is the ID of the line the thread is on in the highlighted snippet,
+// or "" if it isn't on a line.
+func (t Thread) Anchor() string {
+	if t.Line == 0 {
+		return ""
+	}
+
+	return LineAnchor(t.File, t.Line)
+}
+
+// LinePrefix returns the prefix of the IDs of the lines of a file in the
+// highlighted snippet.
+func LinePrefix(file int) string {
+	return fmt.Sprintf("f%d-L", file)
+}
+
+// LineAnchor returns the ID of a line of a file in the highlighted snippet.
+func LineAnchor(file, line int) string {
+	return LinePrefix(file) + strconv.Itoa(line)
+}
+
+// Threads groups comments by the line they're on. The thread on the snippet
+// as a whole comes first, followed by the others in file and line order.
+// Comments keep their order within a thread.
+func Threads(comments []Comment) []Thread {
+	var threads []Thread
+
+	for _, c := range comments {
+		i := slices.IndexFunc(threads, func(t Thread) bool {
+			return t.File == c.File && t.Line == c.Line
+		})
+		if i == -1 {
+			threads = append(threads, Thread{File: c.File, Line: c.Line})
+			i = len(threads) - 1
+		}
+
+		threads[i].Comments = append(threads[i].Comments, c)
+	}
+
+	slices.SortStableFunc(threads, func(a, b Thread) int {
+		if a.File != b.File {
+			return a.File - b.File
+		}
+		return a.Line - b.Line
+	})
+
+	return threads
+}
+
+type CommentModelInterface interface {
+	Insert(c *Comment) error
+	Get(id int) (Comment, error)
+	BySnippet(snippetID int) ([]Comment, error)
+	Update(id int, body string) error
+	Delete(id int) error
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+const commentSelect = `SELECT c.id, c.snippet_id, s.slug, c.user_id, COALESCE(u.name, ''), c.file, c.line, c.body, c.created, c.updated
+    FROM comments c JOIN snippets s ON s.id = c.snippet_id LEFT JOIN users u ON u.id = c.user_id`
+
+func scanComment(row rowScanner) (Comment, error) {
+	var c Comment
+
+	err := row.Scan(&c.ID, &c.SnippetID, &c.SnippetSlug, &c.UserID, &c.UserName, &c.File, &c.Line, &c.Body, &c.Created, &c.Updated)
+
+	return c, err
+}
+
+// Insert creates a comment from the snippet, author, anchor and body of c,
+// and fills in its ID.
+func (m *CommentModel) Insert(c *Comment) error {
+	stmt := `INSERT INTO comments (snippet_id, user_id, file, line, body, created, updated)
+    VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, c.SnippetID, c.UserID, c.File, c.Line, c.Body)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	c.ID = int(id)
+
+	return nil
+}
+
+func (m *CommentModel) Get(id int) (Comment, error) {
+	c, err := scanComment(m.DB.QueryRow(commentSelect+" WHERE c.id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Comment{}, ErrNoRecord
+		}
+		return Comment{}, err
+	}
+
+	return c, nil
+}
+
+// BySnippet returns every comment on a snippet, oldest first.
+func (m *CommentModel) BySnippet(snippetID int) ([]Comment, error) {
+	rows, err := m.DB.Query(commentSelect+" WHERE c.snippet_id = ? ORDER BY c.created, c.id", snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var comments []Comment
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Update replaces the body of a comment. Its anchor can't be changed.
+func (m *CommentModel) Update(id int, body string) error {
+	_, err := m.DB.Exec("UPDATE comments SET body = ?, updated = UTC_TIMESTAMP() WHERE id = ?", body, id)
+
+	return err
+}
+
+func (m *CommentModel) Delete(id int) error {
+	result, err := m.DB.Exec("DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
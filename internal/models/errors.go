@@ -6,4 +6,8 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+
+	// ErrNeedsConfirm is returned by SnippetModel.View when the view would
+	// be a snippet's final one and hasn't been confirmed.
+	ErrNeedsConfirm = errors.New("models: final view needs confirming")
 )
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// File is one named file in a snippet. Position orders the files from 0. The
+// first file is the snippet's main file: its content and language are copied
+// onto the snippet itself, which is what listings work from.
+type File struct {
+	ID       int    `json:"-"`
+	Position int    `json:"-"`
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// Number is the file's 1-based position, as used in its URLs.
+func (f File) Number() int {
+	return f.Position + 1
+}
+
+// DisplayName returns the file's name, or a placeholder if it hasn't got one.
+func (f File) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+
+	return fmt.Sprintf("File %d", f.Number())
+}
+
+// Lines counts the lines in the file, not counting an empty one after a
+// final newline.
+func (f File) Lines() int {
+	if f.Content == "" {
+		return 0
+	}
+
+	return strings.Count(strings.TrimSuffix(f.Content, "\n"), "\n") + 1
+}
+
+// File returns the file with the given 1-based number.
+func (s Snippet) File(number int) (File, bool) {
+	if number < 1 || number > len(s.Files) {
+		return File{}, false
+	}
+
+	return s.Files[number-1], true
+}
+
+// FileName returns the display name of the file with the given 1-based
+// number, or "" if there's no such file.
+func (s Snippet) FileName(number int) string {
+	f, ok := s.File(number)
+	if !ok {
+		return ""
+	}
+
+	return f.DisplayName()
+}
+
+// syncFiles makes s.Files and the main file copied onto s agree before s is
+// written. A snippet without files gets a single unnamed one holding its
+// content.
+func (s *Snippet) syncFiles() {
+	if len(s.Files) == 0 {
+		s.Files = []File{{Language: s.Language, Content: s.Content}}
+	}
+
+	for i := range s.Files {
+		s.Files[i].Position = i
+	}
+
+	s.Content = s.Files[0].Content
+	s.Language = s.Files[0].Language
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// loadFiles fills in the files of s.
+func loadFiles(q queryer, s *Snippet) error {
+	rows, err := q.Query("SELECT id, position, name, language, content FROM snippet_files WHERE snippet_id = ? ORDER BY position", s.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	s.Files = nil
+
+	for rows.Next() {
+		var f File
+
+		err = rows.Scan(&f.ID, &f.Position, &f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return err
+		}
+		s.Files = append(s.Files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Snippets from before files existed are one unnamed file.
+	if len(s.Files) == 0 {
+		s.Files = []File{{Language: s.Language, Content: s.Content}}
+	}
+
+	return nil
+}
+
+// writeFiles replaces the stored files of the snippet with the given ID.
+func writeFiles(tx *sql.Tx, snippetID int, files []File) error {
+	_, err := tx.Exec("DELETE FROM snippet_files WHERE snippet_id = ?", snippetID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+    VALUES(?, ?, ?, ?, ?)`
+
+	for _, f := range files {
+		_, err = tx.Exec(stmt, snippetID, f.Position, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
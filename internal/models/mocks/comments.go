@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	"thabomoyo.co.uk/internal/models"
+)
+
+// mockComment is bob's comment on alice's public snippet.
+var mockComment = models.Comment{
+	ID:          1,
+	SnippetID:   1,
+	SnippetSlug: "PublicPond01",
+	UserID:      2,
+	UserName:    "Bob",
+	Body:        "Lovely pond.",
+	Created:     created,
+	Updated:     created,
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(c *models.Comment) error {
+	c.ID = 2
+	return nil
+}
+
+func (m *CommentModel) Get(id int) (models.Comment, error) {
+	if id != mockComment.ID {
+		return models.Comment{}, models.ErrNoRecord
+	}
+
+	return mockComment, nil
+}
+
+func (m *CommentModel) BySnippet(snippetID int) ([]models.Comment, error) {
+	if snippetID != mockComment.SnippetID {
+		return nil, nil
+	}
+
+	return []models.Comment{mockComment}, nil
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	_, err := m.Get(id)
+	return err
+}
+
+func (m *CommentModel) Delete(id int) error {
+	_, err := m.Get(id)
+	return err
+}
@@ -0,0 +1,173 @@
+// Package mocks has in-memory stand-ins for the models, for testing handlers
+// without a database. Each model serves a fixed set of records.
+package mocks
+
+import (
+	"slices"
+	"strings"
+	"thabomoyo.co.uk/internal/models"
+	"time"
+)
+
+// created is when every mock record was created.
+var created = time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+// mockSnippets returns a fresh copy of the mock snippets. Alice, user 1, has
+// one of each visibility, and Bob, user 2, has a public one.
+func mockSnippets() []models.Snippet {
+	snippet := func(id int, slug, title, visibility string, userID int, userName string) models.Snippet {
+		content := "An old silent pond, a frog jumps into the pond, splash! Silence again."
+
+		return models.Snippet{
+			ID:         id,
+			Slug:       slug,
+			Title:      title,
+			Content:    content,
+			Language:   "plaintext",
+			Format:     models.FormatPlain,
+			Visibility: visibility,
+			Created:    created,
+			Updated:    created,
+			UserID:     userID,
+			UserName:   userName,
+			Files:      []models.File{{Language: "plaintext", Content: content}},
+			Tags:       []string{},
+		}
+	}
+
+	return []models.Snippet{
+		snippet(1, "PublicPond01", "Public pond", models.VisibilityPublic, 1, "Alice"),
+		snippet(2, "UnlistedPnd2", "Unlisted pond", models.VisibilityUnlisted, 1, "Alice"),
+		snippet(3, "PrivatePond3", "Private pond", models.VisibilityPrivate, 1, "Alice"),
+		snippet(4, "BobsPond0004", "Bob's pond", models.VisibilityPublic, 2, "Bob"),
+	}
+}
+
+type SnippetModel struct{}
+
+func (m *SnippetModel) find(match func(models.Snippet) bool) (models.Snippet, error) {
+	for _, s := range mockSnippets() {
+		if match(s) {
+			return s, nil
+		}
+	}
+
+	return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Insert(s *models.Snippet) error {
+	s.ID = 5
+	s.Slug = "NewSnippet05"
+
+	return nil
+}
+
+func (m *SnippetModel) Get(id int) (models.Snippet, error) {
+	return m.find(func(s models.Snippet) bool { return s.ID == id })
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (models.Snippet, error) {
+	return m.find(func(s models.Snippet) bool { return s.Slug == slug })
+}
+
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) View(id int, confirmed bool) (models.Snippet, error) {
+	s, err := m.Get(id)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	if s.Limited() && s.ViewsLeft() == 1 && !confirmed {
+		return models.Snippet{}, models.ErrNeedsConfirm
+	}
+	s.Views++
+
+	return s, nil
+}
+
+// List honours the visibility, user and tag options, which is what the
+// handlers rely on it to filter by, but neither sorts nor pages.
+func (m *SnippetModel) List(opts models.ListOptions) (models.SnippetPage, error) {
+	var page models.SnippetPage
+
+	for _, s := range mockSnippets() {
+		if !opts.AllVisibilities && s.Visibility != models.VisibilityPublic {
+			continue
+		}
+		if opts.UserID != 0 && s.UserID != opts.UserID {
+			continue
+		}
+		if opts.Tag != "" && !slices.Contains(s.Tags, opts.Tag) {
+			continue
+		}
+		page.Snippets = append(page.Snippets, s)
+	}
+
+	return page, nil
+}
+
+func (m *SnippetModel) Update(s models.Snippet, userID int) error {
+	_, err := m.Get(s.ID)
+	return err
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	_, err := m.Get(id)
+	return err
+}
+
+// Search matches the query against titles, searching public snippets and
+// viewerID's own.
+func (m *SnippetModel) Search(query string, viewerID, limit, offset int) ([]models.Snippet, error) {
+	var snippets []models.Snippet
+
+	for _, s := range mockSnippets() {
+		if (s.Visibility == models.VisibilityPublic || s.UserID == viewerID) && strings.Contains(strings.ToLower(s.Title), strings.ToLower(query)) {
+			snippets = append(snippets, s)
+		}
+	}
+
+	if offset >= len(snippets) {
+		return nil, nil
+	}
+
+	return snippets[offset:min(len(snippets), offset+limit)], nil
+}
+
+// Revisions gives every snippet a single revision, its first.
+func (m *SnippetModel) Revisions(snippetID int) ([]models.Revision, error) {
+	r, err := m.Revision(snippetID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.Revision{r}, nil
+}
+
+func (m *SnippetModel) Revision(snippetID, number int) (models.Revision, error) {
+	s, err := m.Get(snippetID)
+	if err != nil || number != 1 {
+		return models.Revision{}, models.ErrNoRecord
+	}
+
+	return models.Revision{
+		ID:        s.ID,
+		SnippetID: s.ID,
+		Number:    1,
+		UserID:    s.UserID,
+		UserName:  s.UserName,
+		Title:     s.Title,
+		Content:   s.Content,
+		Language:  s.Language,
+		Files:     s.Files,
+		Created:   s.Created,
+	}, nil
+}
+
+func (m *SnippetModel) Restore(snippetID, number, userID int) error {
+	_, err := m.Revision(snippetID, number)
+	return err
+}
@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"thabomoyo.co.uk/internal/models"
+	"time"
+)
+
+// StarModel has no stars, and starring toggles nothing.
+type StarModel struct{}
+
+func (m *StarModel) Toggle(snippetID, userID int) (bool, error) {
+	return true, nil
+}
+
+func (m *StarModel) Exists(snippetID, userID int) (bool, error) {
+	return false, nil
+}
+
+func (m *StarModel) ByUser(userID, limit int) ([]models.Snippet, error) {
+	return nil, nil
+}
+
+func (m *StarModel) Popular(since time.Time, limit int) ([]models.Snippet, error) {
+	return nil, nil
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"thabomoyo.co.uk/internal/models"
+)
+
+// MockToken is an API token belonging to user 1.
+const MockToken = "mock-api-token"
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name string) (string, error) {
+	return MockToken, nil
+}
+
+func (m *TokenModel) ByUser(userID int) ([]models.Token, error) {
+	if userID != 1 {
+		return nil, nil
+	}
+
+	return []models.Token{{ID: 1, UserID: 1, Name: "laptop", Created: created}}, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	if id != 1 || userID != 1 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	if plaintext != MockToken {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	return 1, nil
+}
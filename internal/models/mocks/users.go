@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	"thabomoyo.co.uk/internal/models"
+)
+
+var mockUsers = []models.User{
+	{ID: 1, Name: "Alice", Email: "alice@example.com", Created: created},
+	{ID: 2, Name: "Bob", Email: "bob@example.com", Created: created},
+}
+
+// MockPassword is every mock user's password.
+const MockPassword = "pa$$word"
+
+type UserModel struct{}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	for _, u := range mockUsers {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	for _, u := range mockUsers {
+		if u.Email == email && password == MockPassword {
+			return u.ID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	_, err := m.Get(id)
+	return err == nil, nil
+}
+
+func (m *UserModel) Get(id int) (models.User, error) {
+	for _, u := range mockUsers {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+
+	return models.User{}, models.ErrNoRecord
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revision is a snapshot of a snippet's title and files taken every time it's
+// created or changed. Number counts up from 1 per snippet. Content and
+// Language are the main file's, as on Snippet.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Number    int
+	UserID    int
+	UserName  string
+	Title     string
+	Content   string
+	Language  string
+	Files     []File
+	Created   time.Time
+}
+
+const revisionSelect = `SELECT r.id, r.snippet_id, r.number, r.user_id, COALESCE(u.name, ''), r.title, r.content, r.language, r.created
+    FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+func scanRevision(row rowScanner) (Revision, error) {
+	var r Revision
+
+	err := row.Scan(&r.ID, &r.SnippetID, &r.Number, &r.UserID, &r.UserName, &r.Title, &r.Content, &r.Language, &r.Created)
+
+	return r, err
+}
+
+// loadRevisionFiles fills in the files of r.
+func loadRevisionFiles(q queryer, r *Revision) error {
+	rows, err := q.Query("SELECT position, name, language, content FROM snippet_revision_files WHERE revision_id = ? ORDER BY position", r.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	r.Files = nil
+
+	for rows.Next() {
+		var f File
+
+		err = rows.Scan(&f.Position, &f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return err
+		}
+		r.Files = append(r.Files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Revisions from before files were recorded only have the main file.
+	if len(r.Files) == 0 {
+		r.Files = []File{{Language: r.Language, Content: r.Content}}
+	}
+
+	return nil
+}
+
+// addRevision records the current state of a snippet, files included, as its
+// next revision. It must run in the same transaction as the change being
+// recorded, after the snippet row and its files have been written.
+func addRevision(tx *sql.Tx, snippetID, userID int) error {
+	var number int
+
+	err := tx.QueryRow("SELECT COALESCE(MAX(number), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?", snippetID).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, number, user_id, title, content, language, created)
+    SELECT id, ?, ?, title, content, language, UTC_TIMESTAMP() FROM snippets WHERE id = ?`
+
+	result, err := tx.Exec(stmt, number, userID, snippetID)
+	if err != nil {
+		return err
+	}
+
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, name, language, content)
+    SELECT ?, position, name, language, content FROM snippet_files WHERE snippet_id = ?`
+
+	_, err = tx.Exec(stmt, revisionID, snippetID)
+
+	return err
+}
+
+// Revisions returns every revision of a snippet, newest first, without their
+// files.
+func (m *SnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	rows, err := m.DB.Query(revisionSelect+" WHERE r.snippet_id = ? ORDER BY r.number DESC", snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var revisions []Revision
+
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns one revision of a snippet, along with its files.
+func (m *SnippetModel) Revision(snippetID, number int) (Revision, error) {
+	r, err := scanRevision(m.DB.QueryRow(revisionSelect+" WHERE r.snippet_id = ? AND r.number = ?", snippetID, number))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNoRecord
+		}
+		return Revision{}, err
+	}
+
+	err = loadRevisionFiles(m.DB, &r)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	return r, nil
+}
+
+// Restore puts a snippet's title and files back to how they were at the given
+// revision. The restore is itself recorded as a new revision, so nothing in
+// the history is lost. The snippet's expiry is left alone.
+func (m *SnippetModel) Restore(snippetID, number, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := scanRevision(tx.QueryRow(revisionSelect+" WHERE r.snippet_id = ? AND r.number = ?", snippetID, number))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = loadRevisionFiles(tx, &r)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE snippets SET title = ?, content = ?, language = ?, updated = UTC_TIMESTAMP() WHERE id = ?"
+
+	_, err = tx.Exec(stmt, r.Title, r.Files[0].Content, r.Files[0].Language, snippetID)
+	if err != nil {
+		return err
+	}
+
+	err = writeFiles(tx, snippetID, r.Files)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, snippetID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,17 +1,60 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int       `json:"id"`
+	Slug       string    `json:"slug"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Language   string    `json:"language"`
+	Format     string    `json:"format"`
+	Visibility string    `json:"visibility"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+	Expires    time.Time `json:"expires"` // zero if the snippet never expires
+	UserID     int       `json:"user_id"`
+	UserName   string    `json:"user_name"`
+
+	// MaxViews is the number of times the snippet can be viewed before it's
+	// deleted, or 0 for no limit. Views counts the views so far.
+	MaxViews int `json:"max_views"`
+	Views    int `json:"views"`
+
+	// ForkedFrom is the ID of the snippet this one was forked from, or 0.
+	// Forks counts the snippets forked from this one.
+	ForkedFrom int `json:"forked_from,omitempty"`
+	Forks      int `json:"forks"`
+
+	// Stars counts the users who have starred the snippet.
+	Stars int `json:"stars"`
+
+	// Files holds every file in the snippet, main file first. It's filled in
+	// when fetching a single snippet, but not in listings.
+	Files []File `json:"files,omitempty"`
+
+	// Tags are the snippet's tags in alphabetical order.
+	Tags []string `json:"tags"`
+
+	// Protected is set when the snippet has a password, in which case only
+	// its owner and those who know the password may see its content.
+	Protected      bool   `json:"protected"`
+	HashedPassword []byte `json:"-"`
+
+	// RemovePassword asks Update to remove the snippet's password.
+	RemovePassword bool `json:"-"`
 }
 
 type SnippetModel struct {
@@ -19,15 +62,240 @@ type SnippetModel struct {
 }
 
 type SnippetModelInterface interface {
-	Insert(title, content string, expires int) (int, error)
-	Get(id int) (User, error)
-	Latest() ([]Snippet, error)
+	Insert(s *Snippet) error
+	Get(id int) (Snippet, error)
+	GetBySlug(slug string) (Snippet, error)
+	DeleteExpired(limit int) (int, error)
+	View(id int, confirmed bool) (Snippet, error)
+	List(opts ListOptions) (SnippetPage, error)
+	Update(s Snippet, userID int) error
+	Delete(id int) error
+	Search(query string, viewerID, limit, offset int) ([]Snippet, error)
+	Revisions(snippetID int) ([]Revision, error)
+	Revision(snippetID, number int) (Revision, error)
+	Restore(snippetID, number, userID int) error
 }
 
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+// Formats say how a snippet's files are shown: as plain text, as highlighted
+// code, or rendered as Markdown.
+const (
+	FormatPlain    = "plain"
+	FormatCode     = "code"
+	FormatMarkdown = "markdown"
+)
+
+// Markdown reports whether the snippet is rendered as Markdown.
+func (s Snippet) Markdown() bool {
+	return s.Format == FormatMarkdown
+}
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// VisibleByIDTo reports whether the user with the given ID may reach the
+// snippet by its sequential ID rather than its slug. Only public snippets and
+// the user's own can be, so unlisted snippets can't be found by counting.
+func (s Snippet) VisibleByIDTo(userID int) bool {
+	return s.Visibility == VisibilityPublic || (userID != 0 && userID == s.UserID)
+}
+
+// VisibleTo reports whether the user with the given ID may view the snippet.
+// Public and unlisted snippets can be viewed by anyone, including anonymous
+// users (userID 0), while private snippets can only be viewed by their owner.
+func (s Snippet) VisibleTo(userID int) bool {
+	if s.Visibility == VisibilityPrivate {
+		return userID != 0 && userID == s.UserID
+	}
+
+	return true
+}
+
+// Never reports whether the snippet never expires.
+func (s Snippet) Never() bool {
+	return s.Expires.IsZero()
+}
+
+// expiresValue returns the snippet's expiry for writing to the database, where
+// snippets that never expire have a NULL expiry.
+func (s Snippet) expiresValue() sql.NullTime {
+	return sql.NullTime{Time: s.Expires, Valid: !s.Expires.IsZero()}
+}
+
+// Limited reports whether the snippet is deleted after a number of views.
+func (s Snippet) Limited() bool {
+	return s.MaxViews > 0
+}
+
+// ViewsLeft returns how many more times a view-limited snippet can be viewed.
+func (s Snippet) ViewsLeft() int {
+	return max(0, s.MaxViews-s.Views)
+}
+
+// SetPassword protects the snippet with password, storing its bcrypt hash in
+// HashedPassword for Insert or Update to save.
+func (s *Snippet) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	s.HashedPassword = hashedPassword
+	s.Protected = true
+
+	return nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password unlocks the
+// snippet.
+func (s Snippet) CheckPassword(password string) error {
+	if !s.Protected {
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortExpiring = "expiring"
+)
+
+// ListOptions filters and orders a listing of snippets. Zero values mean "no
+// filter". At most one of After and Before should be set.
+type ListOptions struct {
+	Sort          string
+	Size          int
+	After         *Cursor
+	Before        *Cursor
+	UserID        int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	ExpiresWithin time.Duration
+	Tag           string
+
+	// AllVisibilities includes unlisted and private snippets, for listings of
+	// a user's own snippets. Otherwise only public snippets are listed.
+	AllVisibilities bool
+}
+
+// SnippetPage is one page of a listing, with cursors for the pages either
+// side of it. A nil cursor means there is no page in that direction.
+type SnippetPage struct {
+	Snippets []Snippet
+	Next     *Cursor
+	Prev     *Cursor
+}
+
+// Cursor marks a position in a listing: the sort key of a row, plus its ID to
+// break ties.
+type Cursor struct {
+	Key time.Time
+	ID  int
+}
+
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d.%d", c.Key.Unix(), c.ID)
+}
+
+// ParseCursor parses a Cursor from the form produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	key, id, ok := strings.Cut(s, ".")
+	if !ok {
+		return Cursor{}, fmt.Errorf("models: malformed cursor %q", s)
+	}
+
+	unix, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("models: malformed cursor %q", s)
+	}
+
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return Cursor{}, fmt.Errorf("models: malformed cursor %q", s)
+	}
+
+	return Cursor{Key: time.Unix(unix, 0).UTC(), ID: n}, nil
+}
+
+// sortColumn returns the column a listing is ordered by, and whether it's
+// ordered descending.
+func sortColumn(sort string) (string, bool) {
+	switch sort {
+	case SortOldest:
+		return "s.created", false
+	case SortExpiring:
+		return expiresKey, false
+	default:
+		return "s.created", true
+	}
+}
+
+func cursorFor(s Snippet, sort string) Cursor {
+	if sort == SortExpiring {
+		if s.Never() {
+			return Cursor{Key: neverExpires, ID: s.ID}
+		}
+		return Cursor{Key: s.Expires, ID: s.ID}
+	}
+
+	return Cursor{Key: s.Created, ID: s.ID}
+}
+
+// neverExpires stands in for the expiry of snippets that never expire when
+// sorting by expiry, so they sort last and can still be used in a cursor.
+var neverExpires = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+const expiresKey = "COALESCE(s.expires, '9999-12-31 23:59:59')"
+
+// notExpired matches snippets that haven't expired yet, including those that
+// never will.
+const notExpired = "(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())"
+
+// snippetSelect is the shared SELECT used by every snippet query, joining the
+// author so their name can be displayed alongside the snippet, counting its
+// forks and stars, and gathering its tags into a comma-separated list.
+const snippetSelect = `SELECT s.id, s.slug, s.title, s.content, s.language, s.format, s.visibility, s.created, s.updated, s.expires, s.user_id, COALESCE(u.name, ''), s.hashed_password, s.max_views, s.views,
+    s.forked_from, (SELECT COUNT(*) FROM snippets f WHERE f.forked_from = s.id), (SELECT COUNT(*) FROM stars WHERE stars.snippet_id = s.id),
+    (SELECT COALESCE(GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ','), '') FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id)
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnippet(row rowScanner) (Snippet, error) {
 	var s Snippet
+	var expires sql.NullTime
+	var forkedFrom sql.NullInt64
+	var tags string
+
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Format, &s.Visibility, &s.Created, &s.Updated, &expires, &s.UserID, &s.UserName, &s.HashedPassword, &s.MaxViews, &s.Views,
+		&forkedFrom, &s.Forks, &s.Stars, &tags)
+	s.Expires = expires.Time
+	s.Tags = splitTags(tags)
+	s.ForkedFrom = int(forkedFrom.Int64)
+	s.Protected = s.HashedPassword != nil
+
+	return s, err
+}
+
+// Get fetches a snippet by its ID. Expired snippets are ErrNoRecord, even
+// before the reaper has deleted them.
+func (m *SnippetModel) Get(id int) (Snippet, error) {
 	//scan the row data into the Snippet struct
-	err := m.DB.QueryRow("SELECT id, title, content, created, expires FROM snippets WHERE id = ?", id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	s, err := scanSnippet(m.DB.QueryRow(snippetSelect+" WHERE s.id = ? AND "+notExpired, id))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -36,43 +304,399 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 		return Snippet{}, err
 	}
 
+	err = loadFiles(m.DB, &s)
+	if err != nil {
+		return Snippet{}, err
+	}
+
 	return s, nil
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// GetBySlug fetches a snippet by its random slug, as used in share links.
+func (m *SnippetModel) GetBySlug(slug string) (Snippet, error) {
+	s, err := scanSnippet(m.DB.QueryRow(snippetSelect+" WHERE s.slug = ? AND "+notExpired, slug))
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
 
+	err = loadFiles(m.DB, &s)
 	if err != nil {
-		return 0, err
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// Insert creates a snippet from the title, files, format, tags, visibility,
+// password, view limit, expiry, author and fork source of s, along with its
+// first revision. It fills in the new snippet's ID and its randomly generated
+// slug.
+func (m *SnippetModel) Insert(s *Snippet) error {
+	var err error
+
+	// Slugs are long enough that a collision is vanishingly unlikely, but if
+	// one happens just roll a new slug.
+	for range 3 {
+		err = m.insert(s)
+
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "snippets_uc_slug") {
+			continue
+		}
+
+		return err
+	}
+
+	return err
+}
+
+func (m *SnippetModel) insert(s *Snippet) error {
+	slug, err := newSlug()
+	if err != nil {
+		return err
+	}
+
+	s.syncFiles()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (slug, title, content, language, format, visibility, hashed_password, max_views, created, updated, expires, user_id, forked_from)
+    VALUES(?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?, ?)`
+
+	forkedFrom := sql.NullInt64{Int64: int64(s.ForkedFrom), Valid: s.ForkedFrom != 0}
+
+	result, err := tx.Exec(stmt, slug, s.Title, s.Content, s.Language, s.Format, s.Visibility, s.HashedPassword, s.MaxViews, s.expiresValue(), s.UserID, forkedFrom)
+
+	if err != nil {
+		return err
 	}
 
 	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	err = writeFiles(tx, int(id), s.Files)
+	if err != nil {
+		return err
+	}
+
+	err = writeTags(tx, int(id), s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, int(id), s.UserID)
+	if err != nil {
+		return err
+	}
 
-	return int(id), nil
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	s.ID = int(id)
+	s.Slug = slug
+
+	return nil
+}
+
+const (
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	slugLength   = 12
+)
+
+// newSlug returns a random base62 string for addressing a snippet without
+// exposing its sequential ID. 12 characters give about 71 bits of entropy.
+func newSlug() (string, error) {
+	b := make([]byte, slugLength)
+	n := big.NewInt(int64(len(slugAlphabet)))
+
+	for i := range b {
+		c, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b[i] = slugAlphabet[c.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// Update replaces the title, files, format, tags, visibility, view limit and
+// expiry of the snippet with ID s.ID and records the change as a new revision
+// by userID. The password is only replaced if s has a new one, and removed if
+// s.RemovePassword is set.
+func (m *SnippetModel) Update(s Snippet, userID int) error {
+	s.syncFiles()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, format = ?, visibility = ?,
+    hashed_password = IF(?, NULL, COALESCE(?, hashed_password)), max_views = ?, updated = UTC_TIMESTAMP(),
+    expires = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, s.Title, s.Content, s.Language, s.Format, s.Visibility, s.RemovePassword, s.HashedPassword, s.MaxViews, s.expiresValue(), s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = writeFiles(tx, s.ID, s.Files)
+	if err != nil {
+		return err
+	}
+
+	err = writeTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, s.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	// Write the SQL statement we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// View fetches the snippet with the given ID and counts a view of it. A
+// view-limited snippet is deleted by its final view, after which it's
+// ErrNoRecord. The row is locked while the view is counted, so two people
+// can't both be shown the final view. Unless confirmed is set, the final
+// view isn't counted and ErrNeedsConfirm is returned instead, so the reader
+// can be asked first.
+//
+// Get doesn't count views, as it also backs pages like editing and revision
+// history that aren't a reader seeing the snippet.
+func (m *SnippetModel) View(id int, confirmed bool) (Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	s, err := scanSnippet(tx.QueryRow(snippetSelect+" WHERE s.id = ? AND "+notExpired+" FOR UPDATE OF s", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	if s.Limited() && s.ViewsLeft() == 0 {
+		return Snippet{}, ErrNoRecord
+	}
+
+	if s.Limited() && s.ViewsLeft() == 1 && !confirmed {
+		return Snippet{}, ErrNeedsConfirm
+	}
+
+	err = loadFiles(tx, &s)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	s.Views++
+
+	if s.Limited() && s.ViewsLeft() == 0 {
+		_, err = tx.Exec("DELETE FROM snippets WHERE id = ?", id)
+	} else {
+		_, err = tx.Exec("UPDATE snippets SET views = views + 1 WHERE id = ?", id)
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
 
-	rows, err := m.DB.Query(stmt)
+	err = tx.Commit()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	result, err := m.DB.Exec("DELETE FROM snippets WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// DeleteExpired deletes up to limit expired snippets, oldest first, and
+// returns how many it deleted. Their revisions go with them. Deleting in
+// batches keeps each statement's locks short.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	result, err := m.DB.Exec("DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() ORDER BY expires LIMIT ?", limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// List returns one page of unexpired snippets matching opts. Pages are found by
+// keyset pagination, so a page is addressed by the Cursor of the row either
+// side of it rather than by an offset.
+func (m *SnippetModel) List(opts ListOptions) (SnippetPage, error) {
+	where := []string{notExpired}
+	var args []any
+
+	if !opts.AllVisibilities {
+		where = append(where, "s.visibility = 'public'")
+	}
+
+	if opts.UserID != 0 {
+		where = append(where, "s.user_id = ?")
+		args = append(args, opts.UserID)
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, "s.created >= ?")
+		args = append(args, opts.CreatedAfter)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, "s.created < ?")
+		args = append(args, opts.CreatedBefore)
+	}
+	if opts.ExpiresWithin > 0 {
+		where = append(where, "s.expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND)")
+		args = append(args, int(opts.ExpiresWithin.Seconds()))
+	}
+	if opts.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id AND t.name = ?)")
+		args = append(args, opts.Tag)
+	}
+
+	column, descending := sortColumn(opts.Sort)
+
+	// Walking backwards from a cursor flips both the comparison and the
+	// ordering. The rows are put back in display order below.
+	backward := opts.Before != nil
+	cursor := opts.After
+	if backward {
+		cursor = opts.Before
+		descending = !descending
+	}
+
+	if cursor != nil {
+		op := ">"
+		if descending {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%s, s.id) %s (?, ?)", column, op))
+		args = append(args, cursor.Key, cursor.ID)
+	}
+
+	dir := "ASC"
+	if descending {
+		dir = "DESC"
+	}
+
+	size := opts.Size
+	if size < 1 {
+		size = 10
+	}
+
+	// Fetch one extra row so we know whether there's another page.
+	stmt := fmt.Sprintf("%s WHERE %s ORDER BY %s %s, s.id %s LIMIT ?", snippetSelect, strings.Join(where, " AND "), column, dir, dir)
+	args = append(args, size+1)
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return SnippetPage{}, err
+	}
+
+	more := len(snippets) > size
+	if more {
+		snippets = snippets[:size]
+	}
+	if backward {
+		slices.Reverse(snippets)
+	}
+
+	page := SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+
+	first := cursorFor(snippets[0], opts.Sort)
+	last := cursorFor(snippets[len(snippets)-1], opts.Sort)
+
+	if backward {
+		page.Next = &last
+		if more {
+			page.Prev = &first
+		}
+	} else {
+		if more {
+			page.Next = &last
+		}
+		if opts.After != nil {
+			page.Prev = &first
+		}
+	}
+
+	return page, nil
+}
+
+// Search returns unexpired snippets whose title or files match query using
+// the FULLTEXT indexes on snippets and snippet_files, most relevant first.
+// The main file is searched through the copy on the snippet, so only the
+// other files are looked up in snippet_files. Only public snippets and those
+// belonging to viewerID are searched. Other people's password-protected and
+// view-limited snippets are left out too, as matching their content would
+// leak it.
+func (m *SnippetModel) Search(query string, viewerID, limit, offset int) ([]Snippet, error) {
+	stmt := snippetSelect + ` WHERE (MATCH(s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE)
+        OR EXISTS(SELECT true FROM snippet_files f WHERE f.snippet_id = s.id AND f.position > 0
+            AND MATCH(f.content) AGAINST (? IN NATURAL LANGUAGE MODE)))
+    AND ` + notExpired + ` AND ((s.visibility = 'public' AND s.hashed_password IS NULL AND s.max_views = 0) OR s.user_id = ?)
+    ORDER BY MATCH(s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE)
+        + COALESCE((SELECT MAX(MATCH(f.content) AGAINST (? IN NATURAL LANGUAGE MODE)) FROM snippet_files f
+            WHERE f.snippet_id = s.id AND f.position > 0), 0) DESC, s.id DESC
+    LIMIT ? OFFSET ?`
+
+	return m.query(stmt, query, query, viewerID, query, query, limit, offset)
+}
+
+func (m *SnippetModel) query(stmt string, args ...any) ([]Snippet, error) {
+	return querySnippets(m.DB, stmt, args...)
+}
+
+// querySnippets runs a query selecting snippetSelect's columns and scans
+// every row.
+func querySnippets(q queryer, stmt string, args ...any) ([]Snippet, error) {
+	rows, err := q.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	// We defer rows.Close() to ensure that the result set is always properly closed before the Latest() method returns.
+	// We defer rows.Close() to ensure that the result set is always properly closed before the method returns.
 	defer rows.Close()
 
 	var snippets []Snippet
 
 	for rows.Next() {
-		var s Snippet
-
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -85,3 +709,17 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	return snippets, nil
 }
+
+// checkAffected returns ErrNoRecord if a write statement didn't touch any rows.
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
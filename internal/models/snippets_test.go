@@ -0,0 +1,218 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"time"
+)
+
+func TestSnippetVisibleTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		visibility string
+		userID     int
+		want       bool
+	}{
+		{name: "Public anonymous", visibility: VisibilityPublic, userID: 0, want: true},
+		{name: "Unlisted anonymous", visibility: VisibilityUnlisted, userID: 0, want: true},
+		{name: "Private anonymous", visibility: VisibilityPrivate, userID: 0, want: false},
+		{name: "Private other user", visibility: VisibilityPrivate, userID: 2, want: false},
+		{name: "Private owner", visibility: VisibilityPrivate, userID: 1, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Snippet{UserID: 1, Visibility: tt.visibility}
+
+			assert.Equal(t, s.VisibleTo(tt.userID), tt.want)
+		})
+	}
+}
+
+func TestSnippetVisibleByIDTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		visibility string
+		userID     int
+		want       bool
+	}{
+		{name: "Public anonymous", visibility: VisibilityPublic, userID: 0, want: true},
+		{name: "Unlisted anonymous", visibility: VisibilityUnlisted, userID: 0, want: false},
+		{name: "Unlisted other user", visibility: VisibilityUnlisted, userID: 2, want: false},
+		{name: "Unlisted owner", visibility: VisibilityUnlisted, userID: 1, want: true},
+		{name: "Private owner", visibility: VisibilityPrivate, userID: 1, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Snippet{UserID: 1, Visibility: tt.visibility}
+
+			assert.Equal(t, s.VisibleByIDTo(tt.userID), tt.want)
+		})
+	}
+}
+
+func TestSnippetViewsLeft(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		maxViews int
+		views    int
+		want     int
+	}{
+		{name: "Unlimited", maxViews: 0, views: 12, want: 0},
+		{name: "Unviewed", maxViews: 10, views: 0, want: 10},
+		{name: "Final view", maxViews: 10, views: 9, want: 1},
+		{name: "Used up", maxViews: 1, views: 1, want: 0},
+		{name: "Limit lowered", maxViews: 10, views: 25, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Snippet{MaxViews: tt.maxViews, Views: tt.views}
+
+			assert.Equal(t, s.ViewsLeft(), tt.want)
+		})
+	}
+}
+
+func TestSnippetCheckPassword(t *testing.T) {
+	t.Parallel()
+
+	var protected Snippet
+
+	err := protected.SetPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		snippet  Snippet
+		password string
+		want     error
+	}{
+		{name: "Correct password", snippet: protected, password: "correct horse", want: nil},
+		{name: "Wrong password", snippet: protected, password: "battery staple", want: ErrInvalidCredentials},
+		{name: "Empty password", snippet: protected, password: "", want: ErrInvalidCredentials},
+		{name: "Not protected", snippet: Snippet{}, password: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.snippet.CheckPassword(tt.password), tt.want)
+		})
+	}
+}
+
+func TestNewSlug(t *testing.T) {
+	t.Parallel()
+
+	seen := map[string]bool{}
+
+	for range 100 {
+		slug, err := newSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(slug), slugLength)
+		assert.Equal(t, strings.Trim(slug, slugAlphabet), "")
+		assert.Equal(t, seen[slug], false)
+
+		seen[slug] = true
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	t.Parallel()
+
+	c := Cursor{Key: time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC), ID: 42}
+
+	parsed, err := ParseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, parsed, c)
+
+	for _, s := range []string{"", "42", "abc.42", "1710670500.x", "1710670500.0"} {
+		_, err := ParseCursor(s)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Empty", input: "", want: ""},
+		{name: "Blanks only", input: " , ,", want: ""},
+		{name: "Single", input: "go", want: "go"},
+		{name: "Trimmed and lower-cased", input: " Go ,  SQL ", want: "go,sql"},
+		{name: "Sorted", input: "web,go,api", want: "api,go,web"},
+		{name: "Duplicates dropped", input: "go,Go, go", want: "go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, strings.Join(ParseTags(tt.input), ","), tt.want)
+		})
+	}
+}
+
+func TestThreads(t *testing.T) {
+	t.Parallel()
+
+	comments := []Comment{
+		{ID: 1, File: 1, Line: 7},
+		{ID: 2},
+		{ID: 3, File: 2, Line: 1},
+		{ID: 4, File: 1, Line: 3},
+		{ID: 5, File: 1, Line: 7},
+		{ID: 6},
+	}
+
+	var got []string
+	for _, thread := range Threads(comments) {
+		var ids []string
+		for _, c := range thread.Comments {
+			ids = append(ids, strconv.Itoa(c.ID))
+		}
+		got = append(got, thread.Anchor()+":"+strings.Join(ids, ","))
+	}
+
+	assert.Equal(t, strings.Join(got, " "), ":2,6 f1-L3:4 f1-L7:1,5 f2-L1:3")
+}
+
+func TestFileLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{content: "", want: 0},
+		{content: "one", want: 1},
+		{content: "one\n", want: 1},
+		{content: "one\ntwo", want: 2},
+		{content: "one\n\nthree\n", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.content), func(t *testing.T) {
+			assert.Equal(t, File{Content: tt.content}.Lines(), tt.want)
+		})
+	}
+}
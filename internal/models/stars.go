@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type StarModelInterface interface {
+	Toggle(snippetID, userID int) (bool, error)
+	Exists(snippetID, userID int) (bool, error)
+	ByUser(userID, limit int) ([]Snippet, error)
+	Popular(since time.Time, limit int) ([]Snippet, error)
+}
+
+type StarModel struct {
+	DB *sql.DB
+}
+
+// Toggle stars the snippet for the user, or unstars it if they'd already
+// starred it. It reports whether the snippet is starred afterwards.
+func (m *StarModel) Toggle(snippetID, userID int) (bool, error) {
+	result, err := m.DB.Exec("DELETE FROM stars WHERE snippet_id = ? AND user_id = ?", snippetID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, nil
+	}
+
+	// IGNORE covers a double-submitted form starring the snippet in between.
+	stmt := `INSERT IGNORE INTO stars (snippet_id, user_id, created)
+    VALUES(?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, snippetID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Exists reports whether the user has starred the snippet.
+func (m *StarModel) Exists(snippetID, userID int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM stars WHERE snippet_id = ? AND user_id = ?)"
+
+	err := m.DB.QueryRow(stmt, snippetID, userID).Scan(&exists)
+
+	return exists, err
+}
+
+// ByUser returns the unexpired snippets the user has starred, most recently
+// starred first. Snippets that have since been made private by someone else
+// are left out.
+func (m *StarModel) ByUser(userID, limit int) ([]Snippet, error) {
+	stmt := snippetSelect + ` JOIN stars star ON star.snippet_id = s.id
+    WHERE star.user_id = ? AND ` + notExpired + ` AND (s.visibility <> 'private' OR s.user_id = ?)
+    ORDER BY star.created DESC, s.id DESC LIMIT ?`
+
+	return querySnippets(m.DB, stmt, userID, userID, limit)
+}
+
+// Popular returns the unexpired public snippets starred most since the given
+// time, or ever if it's zero. Snippets without stars in that time aren't
+// included.
+func (m *StarModel) Popular(since time.Time, limit int) ([]Snippet, error) {
+	if since.IsZero() {
+		since = time.Unix(0, 0).UTC()
+	}
+
+	stmt := snippetSelect + ` JOIN (SELECT snippet_id, COUNT(*) AS n FROM stars WHERE created >= ? GROUP BY snippet_id) p ON p.snippet_id = s.id
+    WHERE s.visibility = 'public' AND ` + notExpired + `
+    ORDER BY p.n DESC, s.id DESC LIMIT ?`
+
+	return querySnippets(m.DB, stmt, since, limit)
+}
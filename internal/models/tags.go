@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+// ParseTags splits a comma-separated list of tags, lower-casing them and
+// dropping blanks and duplicates. The result is sorted, matching the order
+// tags are loaded in.
+func ParseTags(s string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	slices.Sort(tags)
+
+	return tags
+}
+
+// splitTags splits the comma-separated tags gathered by snippetSelect.
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+// writeTags replaces the tags of the snippet with the given ID, creating any
+// tags that don't exist yet. Tags no longer used by any snippet are left in
+// place; they cost a row each and keep their IDs stable.
+func writeTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// LAST_INSERT_ID(id) makes an existing tag report its own ID, so a
+		// single statement finds or creates it.
+		result, err := tx.Exec("INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)", tag)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)", snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+type TokenModelInterface interface {
+	Insert(userID int, name string) (string, error)
+	ByUser(userID int) ([]Token, error)
+	Delete(id, userID int) error
+	Authenticate(plaintext string) (int, error)
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// hashToken returns the SHA-256 hash stored in place of a token. Tokens are
+// long and random, so unlike passwords they don't need a slow hash.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Insert creates a new token for the user and returns its plaintext. Only the
+// hash is stored, so this is the only time the plaintext is available.
+func (m *TokenModel) Insert(userID int, name string) (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+func (m *TokenModel) ByUser(userID int) ([]Token, error) {
+	stmt := `SELECT id, user_id, name, created, last_used FROM api_tokens
+    WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tokens []Token
+
+	for rows.Next() {
+		var t Token
+		var lastUsed sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes a token, so long as it belongs to the given user.
+func (m *TokenModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// Authenticate returns the ID of the user owning the token, recording that
+// the token has been used.
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	var id, userID int
+
+	hash := hashToken(plaintext)
+
+	err := m.DB.QueryRow("SELECT id, user_id FROM api_tokens WHERE token_hash = ?", hash).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	_, err = m.DB.Exec("UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
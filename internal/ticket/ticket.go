@@ -0,0 +1,94 @@
+// Package ticket issues and checks short-lived signed tickets. The web app
+// gives one to a signed-in user, who presents it to the WebSocket server to
+// prove who they are without that server needing the session store.
+package ticket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTL is how long a ticket is valid for after it's issued. It only needs to
+// last until the browser has opened its connection.
+const TTL = time.Minute
+
+var (
+	ErrInvalid = errors.New("ticket: invalid ticket")
+	ErrExpired = errors.New("ticket: expired ticket")
+)
+
+// Claims are what a ticket vouches for: who the user is, and which channels
+// the web app has checked they may subscribe to.
+type Claims struct {
+	UserID   int
+	Channels []string
+}
+
+// Issue returns a ticket for the user and channels, signed with secret, and
+// when it expires. Channel names mustn't contain commas. Tickets look like
+// "<user ID>.<expiry>.<channels>.<signature>", with the channels
+// comma-separated and base64url-encoded.
+func Issue(secret []byte, claims Claims, now time.Time) (string, time.Time) {
+	expires := now.Add(TTL).Truncate(time.Second)
+	channels := base64.RawURLEncoding.EncodeToString([]byte(strings.Join(claims.Channels, ",")))
+	payload := strconv.Itoa(claims.UserID) + "." + strconv.FormatInt(expires.Unix(), 10) + "." + channels
+
+	return payload + "." + sign(secret, payload), expires
+}
+
+// Verify checks a ticket's signature and expiry, and returns what it vouches
+// for.
+func Verify(secret []byte, ticket string, now time.Time) (Claims, error) {
+	i := strings.LastIndexByte(ticket, '.')
+	if i < 0 || len(secret) == 0 {
+		return Claims{}, ErrInvalid
+	}
+	payload, signature := ticket[:i], ticket[i+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, payload))) {
+		return Claims{}, ErrInvalid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalid
+	}
+
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil || userID < 1 {
+		return Claims{}, ErrInvalid
+	}
+
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+
+	channels, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+
+	if !now.Before(time.Unix(unix, 0)) {
+		return Claims{}, ErrExpired
+	}
+
+	claims := Claims{UserID: userID}
+	if len(channels) > 0 {
+		claims.Channels = strings.Split(string(channels), ",")
+	}
+
+	return claims, nil
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,97 @@
+package ticket
+
+import (
+	"strings"
+	"testing"
+	"thabomoyo.co.uk/internal/assert"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	issued := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	valid, expires := Issue(secret, Claims{UserID: 7, Channels: []string{"snippet:1", "user.7"}}, issued)
+	bare, _ := Issue(secret, Claims{UserID: 7}, issued)
+
+	assert.Equal(t, expires, issued.Add(TTL))
+
+	tests := []struct {
+		name         string
+		secret       []byte
+		ticket       string
+		now          time.Time
+		wantID       int
+		wantChannels string
+		err          error
+	}{
+		{
+			name:         "Valid",
+			secret:       secret,
+			ticket:       valid,
+			now:          issued.Add(30 * time.Second),
+			wantID:       7,
+			wantChannels: "snippet:1 user.7",
+		},
+		{
+			name:   "No channels",
+			secret: secret,
+			ticket: bare,
+			now:    issued,
+			wantID: 7,
+		},
+		{
+			name:   "Expired",
+			secret: secret,
+			ticket: valid,
+			now:    expires,
+			err:    ErrExpired,
+		},
+		{
+			name:   "Wrong secret",
+			secret: []byte("other"),
+			ticket: valid,
+			now:    issued,
+			err:    ErrInvalid,
+		},
+		{
+			name:   "No secret",
+			secret: nil,
+			ticket: valid,
+			now:    issued,
+			err:    ErrInvalid,
+		},
+		{
+			name:   "Tampered user ID",
+			secret: secret,
+			ticket: "8" + valid[1:],
+			now:    issued,
+			err:    ErrInvalid,
+		},
+		{
+			name:   "Malformed",
+			secret: secret,
+			ticket: "not-a-ticket",
+			now:    issued,
+			err:    ErrInvalid,
+		},
+		{
+			name:   "Empty",
+			secret: secret,
+			ticket: "",
+			now:    issued,
+			err:    ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.secret, tt.ticket, tt.now)
+
+			assert.Equal(t, claims.UserID, tt.wantID)
+			assert.Equal(t, strings.Join(claims.Channels, " "), tt.wantChannels)
+			assert.Equal(t, err, tt.err)
+		})
+	}
+}
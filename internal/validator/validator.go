@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -17,6 +18,10 @@ type Validator struct {
 
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX matches a tag: lower-case letters and digits, with single hyphens
+// between them.
+var TagRX = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
 func (v *Validator) Valid() bool {
 	return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
 }
@@ -82,3 +87,9 @@ func MaxWordCount(value string, n int) bool {
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
+
+// TimeBetween returns true if a time is no earlier than earliest and no later
+// than latest.
+func TimeBetween(value, earliest, latest time.Time) bool {
+	return !value.Before(earliest) && !value.After(latest)
+}
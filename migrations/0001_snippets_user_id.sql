@@ -0,0 +1,16 @@
+-- Snippets belong to the user who created them. Existing snippets are given
+-- to the first user, or deleted if there are no users, so that the column
+-- can be made NOT NULL and the foreign key added.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+UPDATE snippets SET user_id = (SELECT MIN(id) FROM users)
+    WHERE user_id IS NULL;
+
+DELETE FROM snippets WHERE user_id IS NULL;
+
+ALTER TABLE snippets MODIFY user_id INTEGER NOT NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user
+    FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE INDEX idx_snippets_user ON snippets(user_id);
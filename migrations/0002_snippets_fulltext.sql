@@ -0,0 +1,2 @@
+-- Full-text search over snippet titles and content.
+ALTER TABLE snippets ADD FULLTEXT INDEX idx_snippets_fulltext (title, content);
@@ -0,0 +1,4 @@
+-- Keyset pagination of the listing, newest first or soonest to expire.
+CREATE INDEX idx_snippets_created ON snippets(created, id);
+
+CREATE INDEX idx_snippets_expires ON snippets(expires, id);
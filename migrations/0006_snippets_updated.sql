@@ -0,0 +1,7 @@
+-- When a snippet was last changed, for Last-Modified. Existing snippets were
+-- last changed when they were created.
+ALTER TABLE snippets ADD COLUMN updated DATETIME NULL;
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets MODIFY updated DATETIME NOT NULL;
@@ -0,0 +1,17 @@
+-- Every version of each snippet. Existing snippets start with one revision
+-- holding their current state.
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(20) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_number UNIQUE (snippet_id, number),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+INSERT INTO snippet_revisions (snippet_id, number, user_id, title, content, language, created)
+    SELECT id, 1, user_id, title, content, language, updated FROM snippets;
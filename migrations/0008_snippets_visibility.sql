@@ -0,0 +1,5 @@
+-- Who can see a snippet. Existing snippets stay public.
+ALTER TABLE snippets ADD COLUMN visibility ENUM('public', 'unlisted', 'private')
+    NOT NULL DEFAULT 'public';
+
+CREATE INDEX idx_snippets_visibility ON snippets(visibility, created, id);
@@ -0,0 +1,9 @@
+-- Unguessable slugs for share links. Existing snippets get random ones
+-- before the column is made NOT NULL and unique.
+ALTER TABLE snippets ADD COLUMN slug CHAR(12) CHARACTER SET ascii COLLATE ascii_bin NULL;
+
+UPDATE snippets SET slug = LEFT(REPLACE(REPLACE(REPLACE(
+    TO_BASE64(RANDOM_BYTES(16)), '+', ''), '/', ''), '=', ''), 12);
+
+ALTER TABLE snippets MODIFY slug CHAR(12) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
@@ -0,0 +1,2 @@
+-- A bcrypt hash of the snippet's password, or NULL if it hasn't got one.
+ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) NULL;
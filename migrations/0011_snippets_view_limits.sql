@@ -0,0 +1,5 @@
+-- How many views a snippet is limited to, 0 for no limit, and how many it
+-- has had.
+ALTER TABLE snippets
+    ADD COLUMN max_views INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN views INT UNSIGNED NOT NULL DEFAULT 0;
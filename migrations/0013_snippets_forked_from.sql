@@ -0,0 +1,3 @@
+-- The snippet a fork was made from, cleared if that snippet is deleted.
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL,
+    ADD FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL;
@@ -0,0 +1,34 @@
+-- The files in each snippet. Existing snippets become a single unnamed file.
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position),
+    FULLTEXT INDEX idx_snippet_files_content (content),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+INSERT INTO snippet_files (snippet_id, position, name, language, content)
+    SELECT id, 0, '', language, content FROM snippets;
+
+-- The files in each revision. Only the latest revision of each snippet can
+-- be filled in, from the snippet's files. Older ones keep just the main file
+-- held on snippet_revisions.
+CREATE TABLE snippet_revision_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    revision_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position),
+    FOREIGN KEY (revision_id) REFERENCES snippet_revisions(id) ON DELETE CASCADE
+);
+
+INSERT INTO snippet_revision_files (revision_id, position, name, language, content)
+    SELECT r.id, f.position, f.name, f.language, f.content
+    FROM snippet_revisions r INNER JOIN snippet_files f ON f.snippet_id = r.snippet_id
+    WHERE r.number = (SELECT MAX(number) FROM snippet_revisions WHERE snippet_id = r.snippet_id);
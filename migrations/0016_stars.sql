@@ -0,0 +1,11 @@
+-- Which users have starred which snippets, and when.
+CREATE TABLE stars (
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, user_id),
+    INDEX idx_stars_user_created (user_id, created),
+    INDEX idx_stars_created (created),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- How a snippet is shown: plain, code or markdown.
+ALTER TABLE snippets ADD COLUMN format VARCHAR(10) NOT NULL DEFAULT 'code' AFTER language;
@@ -5,4 +5,31 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Tell viewers of a snippet when it changes, so they can reload to see it.
+var liveNotice = document.querySelector("[data-live]");
+if (liveNotice && window.WebSocket) {
+	var scheme = window.location.protocol == "https:" ? "wss://" : "ws://";
+	var socket = new WebSocket(scheme + window.location.host + liveNotice.dataset.live);
+	socket.onmessage = function (message) {
+		var event = JSON.parse(message.data);
+		if (event.type == "deleted") {
+			liveNotice.textContent = "This snippet has been deleted.";
+			socket.close();
+		} else {
+			var what = event.type == "commented" ? "There are new comments on this snippet." : "This snippet has been edited.";
+			liveNotice.textContent = what + " ";
+			var reload = document.createElement("a");
+			reload.href = window.location.href;
+			reload.textContent = "Reload";
+			reload.onclick = function (e) {
+				// Following a link to the same page with a #fragment only scrolls.
+				e.preventDefault();
+				window.location.reload();
+			};
+			liveNotice.appendChild(reload);
+		}
+		liveNotice.hidden = false;
+	};
+}